@@ -0,0 +1,80 @@
+package fibheap
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// SetAging makes the priority of every value improve by rate per second while it waits, so that values
+// with large priorities cannot starve under a steady load of smaller ones. With a non-zero interval, the
+// improvement is applied in steps of rate·interval at every interval boundary instead of continuously.
+// A zero rate turns aging off, keeping the priorities reached so far.
+//
+// Aging never reorders the heap: the heap stores each priority plus the improvement accumulated before
+// the value arrived, and subtracts the improvement accumulated so far whenever a priority is read.
+// Every priority passed in or returned, including those given to hooks, is the effective one at the time of the call.
+func (heap *FibHeap[t]) SetAging(rate float64, interval time.Duration) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	credit := heap.credit()
+	for _, node := range heap.index {
+		node.priority -= credit
+	}
+	for _, l := range heap.leases {
+		l.priority -= credit
+	}
+	heap.lastMinPriority -= credit
+
+	heap.agingRate = rate
+	heap.agingInterval = interval
+	heap.agingSince = heap.clock.Now()
+}
+
+// credit returns how much every priority has improved through aging since it was turned on.
+func (heap *FibHeap[t]) credit() float64 {
+	if heap.agingRate == 0 {
+		return 0
+	}
+
+	elapsed := heap.clock.Now().Sub(heap.agingSince)
+	if heap.agingInterval > 0 {
+		elapsed = elapsed.Truncate(heap.agingInterval)
+	}
+
+	return heap.agingRate * elapsed.Seconds()
+}
+
+// AddToAll shifts the priority of every value in the heap by delta in O(1).
+// The shift is kept as an offset folded into every priority passed in or returned,
+// including those reported by Stats and by the hooks. If the heap is not empty, OnMinChange
+// reports the shifted priority of the minimum.
+// Returns an error if delta is not finite.
+func (heap *FibHeap[t]) AddToAll(delta float64) error {
+	if math.IsInf(delta, 0) || math.IsNaN(delta) {
+		return errors.New("Delta must be finite")
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.offset += delta
+
+	// The stored keys do not change, so unlock cannot see the shift and the event is emitted here.
+	if delta != 0 && heap.min != nil {
+		heap.emit(eventMinChange, heap.min.data, 0, heap.min.priority)
+	}
+
+	return nil
+}
+
+// toKey converts an effective priority into the key stored in the heap.
+func (heap *FibHeap[t]) toKey(priority float64) float64 {
+	return priority + heap.credit() - heap.offset
+}
+
+// fromKey converts a key stored in the heap into its effective priority.
+func (heap *FibHeap[t]) fromKey(key float64) float64 {
+	return key - heap.credit() + heap.offset
+}
@@ -0,0 +1,55 @@
+package fibheap
+
+import "context"
+
+type binding struct {
+	ctx  context.Context
+	stop func() bool
+}
+
+// InsertCtx inserts a new value with the given data and priority into the heap, bound to ctx.
+// Once ctx is done, the value is deleted from the heap as by Delete, unless it was removed before.
+// If the heap is full and uses the Block policy, it waits for space until ctx is done.
+// Returns an error if the insertion fails or ctx is already done.
+func (heap *FibHeap[t]) InsertCtx(ctx context.Context, data t, priority float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if err := heap.insertWait(ctx, data, heap.toKey(priority)); err != nil {
+		return err
+	}
+
+	heap.bind(ctx, data)
+	return nil
+}
+
+// bind deletes the value with the given data once ctx is done.
+// The binding is checked on expiry, so that a value removed and inserted again is left alone.
+func (heap *FibHeap[t]) bind(ctx context.Context, data t) {
+	b := &binding{ctx: ctx}
+	b.stop = context.AfterFunc(ctx, func() {
+		heap.mutex.Lock()
+		defer heap.release()
+
+		if heap.bound[data] == b {
+			heap.deleteNode(heap.index[data])
+		}
+	})
+	heap.bound[data] = b
+}
+
+// unbind releases the binding of the value with the given data, if any, and returns it.
+func (heap *FibHeap[t]) unbind(data t) *binding {
+	b, exists := heap.bound[data]
+	if !exists {
+		return nil
+	}
+
+	b.stop()
+	delete(heap.bound, data)
+	return b
+}
@@ -0,0 +1,230 @@
+package fibheap
+
+import (
+	binheap "container/heap"
+	"context"
+	"errors"
+	"math"
+)
+
+// ErrFull is returned when a bounded heap has no room for a new value.
+var ErrFull = errors.New("Heap is full")
+
+// SetCapacity bounds the heap to at most max values, leased values included; zero removes the bound.
+// The policy decides what Insert does when the heap is full. With the Evict policy
+// the heap also tracks its maximum, so it can be used to keep the best max values.
+// The policy is shared with SetBudget.
+func (heap *FibHeap[t]) SetCapacity(max uint, policy OverflowPolicy) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.capacity = max
+	heap.setPolicy(policy)
+}
+
+// SetBudget bounds the total weight of the heap to budget, where sizer gives the weight of each value;
+// a zero budget removes the bound. Leased values count toward the budget. The policy decides what Insert does
+// when a value does not fit and is shared with SetCapacity. Values heavier than the whole budget are always rejected with ErrFull.
+func (heap *FibHeap[t]) SetBudget(sizer func(t) int, budget int, policy OverflowPolicy) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.sizer = sizer
+	heap.budget = budget
+	heap.weight = 0
+	for _, node := range heap.index {
+		node.size = heap.sizeOf(node.data)
+		heap.weight += node.size
+	}
+	heap.leaseWeight = 0
+	for data, l := range heap.leases {
+		l.size = heap.sizeOf(data.(t))
+		heap.leaseWeight += l.size
+	}
+
+	heap.setPolicy(policy)
+}
+
+// Weight returns the total weight of the values in the heap as measured by the sizer given to SetBudget.
+// Leased values are not included.
+func (heap *FibHeap[t]) Weight() int {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	return heap.weight
+}
+
+// InsertWait inserts a new value with the given data and priority into the heap.
+// If the heap is full and uses the Block policy, it waits until space is freed or ctx is done.
+// Returns an error if the insertion fails.
+func (heap *FibHeap[t]) InsertWait(ctx context.Context, data t, priority float64) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	return heap.insertWait(ctx, data, heap.toKey(priority))
+}
+
+// Maximum returns the current maximum data and priority in the heap.
+// It is O(1) with the Evict policy and a linear scan otherwise.
+// Returns -inf if the heap is empty.
+func (heap *FibHeap[t]) Maximum() (data t, f float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.num == 0 {
+		return data, math.Inf(-1)
+	}
+
+	if heap.maxHeap != nil {
+		max := (*heap.maxHeap)[0]
+		return max.data, heap.fromKey(max.priority)
+	}
+
+	var max *node[t]
+	for _, node := range heap.index {
+		if max == nil || node.priority > max.priority {
+			max = node
+		}
+	}
+
+	return max.data, heap.fromKey(max.priority)
+}
+
+func (heap *FibHeap[t]) setPolicy(policy OverflowPolicy) {
+	heap.policy = policy
+
+	if policy == Evict && heap.maxHeap == nil {
+		heap.maxHeap = newMaxHeap(heap.index)
+	} else if policy != Evict {
+		heap.maxHeap = nil
+	}
+
+	if policy == Evict {
+		for heap.num > 0 && heap.full(0, 0) {
+			heap.deleteNode((*heap.maxHeap)[0])
+		}
+	}
+
+	heap.space.Broadcast()
+}
+
+func (heap *FibHeap[t]) sizeOf(data t) int {
+	if heap.sizer == nil {
+		return 0
+	}
+	return heap.sizer(data)
+}
+
+// full reports whether the heap, leased values included, is over its bounds after adding count values weighing size in total.
+func (heap *FibHeap[t]) full(count uint, size int) bool {
+	if heap.capacity > 0 && heap.num+uint(len(heap.leases))+count > heap.capacity {
+		return true
+	}
+	return heap.budget > 0 && heap.weight+heap.leaseWeight+size > heap.budget
+}
+
+func (heap *FibHeap[t]) insertWait(ctx context.Context, data t, priority float64) error {
+	if heap.closed {
+		return ErrClosed
+	}
+
+	if err := heap.validate(data, priority); err != nil {
+		return err
+	}
+
+	if err := heap.makeRoom(ctx, priority, heap.sizeOf(data)); err != nil {
+		return err
+	}
+
+	if err := heap.insert(data, priority); err != nil {
+		return err
+	}
+
+	heap.cond.Signal()
+	return nil
+}
+
+// makeRoom applies the overflow policy until the heap can take one more value with the given priority and size.
+func (heap *FibHeap[t]) makeRoom(ctx context.Context, priority float64, size int) error {
+	var stop func() bool
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+
+	if heap.budget > 0 && size > heap.budget {
+		return ErrFull
+	}
+
+	for heap.full(1, size) {
+		switch heap.policy {
+		case Evict:
+			// Leased values cannot be evicted, so a heap full of them rejects the value.
+			if heap.num == 0 {
+				return ErrFull
+			}
+			worst := (*heap.maxHeap)[0]
+			if priority >= worst.priority {
+				return ErrFull
+			}
+			heap.deleteNode(worst)
+		case Block:
+			if heap.closed {
+				return ErrClosed
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if stop == nil {
+				stop = context.AfterFunc(ctx, func() {
+					heap.mutex.Lock()
+					defer heap.mutex.Unlock()
+					heap.space.Broadcast()
+				})
+			}
+			heap.space.Wait()
+		default:
+			return ErrFull
+		}
+	}
+
+	return nil
+}
+
+// maxHeap is a binary max-heap over the nodes of a FibHeap, kept only for the Evict policy.
+type maxHeap[t any] []*node[t]
+
+func newMaxHeap[t any](index map[interface{}]*node[t]) *maxHeap[t] {
+	h := make(maxHeap[t], 0, len(index))
+	for _, node := range index {
+		node.maxIndex = len(h)
+		h = append(h, node)
+	}
+	binheap.Init(&h)
+	return &h
+}
+
+func (h maxHeap[t]) Len() int { return len(h) }
+
+func (h maxHeap[t]) Less(i, j int) bool { return h[i].priority > h[j].priority }
+
+func (h maxHeap[t]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].maxIndex = i
+	h[j].maxIndex = j
+}
+
+func (h *maxHeap[t]) Push(x any) {
+	n := x.(*node[t])
+	n.maxIndex = len(*h)
+	*h = append(*h, n)
+}
+
+func (h *maxHeap[t]) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,39 @@
+package fibheap
+
+import "time"
+
+// Clock tells the time and schedules callbacks for the time-based features of the heap.
+// It can be replaced with SetClock so that tests run without real sleeps.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a callback scheduled by a Clock.
+type Timer interface {
+	// Stop prevents the callback from running and reports whether it did so.
+	Stop() bool
+}
+
+// TimePriority converts a time to a priority in fractional Unix seconds, so that earlier times come first.
+func TimePriority(at time.Time) float64 {
+	return float64(at.UnixNano()) / 1e9
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SetClock replaces the clock used by the time-based features of the heap.
+func (heap *FibHeap[t]) SetClock(clock Clock) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	heap.clock = clock
+}
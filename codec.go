@@ -0,0 +1,47 @@
+package fibheap
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec encodes and decodes the data of values when a heap is serialized.
+type Codec[t any] interface {
+	Encode(data t) ([]byte, error)
+	Decode(payload []byte) (t, error)
+}
+
+// GobCodec is the default Codec, encoding each value with encoding/gob.
+// Interface types must be registered with gob.Register.
+type GobCodec[t any] struct{}
+
+// Encode encodes data with encoding/gob.
+func (GobCodec[t]) Encode(data t) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(&data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decode decodes a payload produced by Encode.
+func (GobCodec[t]) Decode(payload []byte) (data t, err error) {
+	err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&data)
+	return data, err
+}
+
+// SetCodec replaces the codec used to serialize the data of values.
+// A nil codec restores the default GobCodec.
+func (heap *FibHeap[t]) SetCodec(codec Codec[t]) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	heap.codec = codec
+}
+
+func (heap *FibHeap[t]) payloadCodec() Codec[t] {
+	if heap.codec == nil {
+		return GobCodec[t]{}
+	}
+	return heap.codec
+}
@@ -0,0 +1,206 @@
+package fibheap
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DelayQueue holds values until the time they are scheduled for.
+// It is a FibHeap prioritized by TimePriority of each deadline, and keeps the exact deadlines aside.
+type DelayQueue[t any] struct {
+	heap      *FibHeap[t]
+	deadlines map[interface{}]time.Time
+	changed   chan struct{}
+}
+
+// NewDelayQueue creates an empty delay queue using the system clock.
+func NewDelayQueue[t any]() *DelayQueue[t] {
+	queue := new(DelayQueue[t])
+	queue.heap = NewFibHeap[t]()
+	queue.deadlines = make(map[interface{}]time.Time)
+	queue.changed = make(chan struct{})
+
+	return queue
+}
+
+// SetClock replaces the clock used by WaitNext.
+func (queue *DelayQueue[t]) SetClock(clock Clock) {
+	queue.heap.SetClock(clock)
+}
+
+// Num returns the total number of values in the queue.
+func (queue *DelayQueue[t]) Num() uint {
+	return queue.heap.Num()
+}
+
+// Schedule adds a value to the queue, due at the given time.
+// Returns an error if the value is already scheduled.
+func (queue *DelayQueue[t]) Schedule(data t, at time.Time) error {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	if err := heap.insert(data, TimePriority(at)); err != nil {
+		return err
+	}
+
+	queue.deadlines[data] = at
+	queue.notify()
+	return nil
+}
+
+// Reschedule moves the deadline of a scheduled value, using DecreasePriority or IncreasePriority underneath.
+// Returns an error if the value is not scheduled.
+func (queue *DelayQueue[t]) Reschedule(data t, at time.Time) error {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	node, exists := heap.index[data]
+	if !exists {
+		return errors.New("Value is not found")
+	}
+
+	var err error
+	switch priority := TimePriority(at); {
+	case priority < node.priority:
+		err = heap.decreaseKey(node, priority)
+	case priority > node.priority:
+		err = heap.increaseKey(node, priority)
+	}
+	if err != nil {
+		return err
+	}
+
+	queue.deadlines[data] = at
+	queue.notify()
+	return nil
+}
+
+// Cancel removes a scheduled value from the queue.
+// Returns an error if the value is not scheduled.
+func (queue *DelayQueue[t]) Cancel(data t) error {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	node, exists := heap.index[data]
+	if !exists {
+		return errors.New("Value is not found")
+	}
+
+	heap.deleteNode(node)
+	delete(queue.deadlines, data)
+	queue.notify()
+	return nil
+}
+
+// NextDeadline returns the earliest deadline in the queue, and false if the queue is empty.
+func (queue *DelayQueue[t]) NextDeadline() (time.Time, bool) {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.num == 0 {
+		return time.Time{}, false
+	}
+
+	return queue.deadlines[heap.min.data], true
+}
+
+// PopReady removes and returns every value due at or before now, earliest first.
+func (queue *DelayQueue[t]) PopReady(now time.Time) []t {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	var ready []t
+	for {
+		data, ok := queue.popReady(now)
+		if !ok {
+			return ready
+		}
+		ready = append(ready, data)
+	}
+}
+
+// WaitNext blocks until the earliest value is due, then removes and returns it.
+// A value scheduled ahead of the one being waited for is picked up straight away.
+// Returns the context error if ctx is done first, or ErrClosed once the queue is closed and no value is due.
+func (queue *DelayQueue[t]) WaitNext(ctx context.Context) (data t, err error) {
+	heap := queue.heap
+	for {
+		heap.mutex.Lock()
+		now := heap.clock.Now()
+		if data, ok := queue.popReady(now); ok {
+			heap.unlock()
+			return data, nil
+		}
+		if heap.closed {
+			heap.unlock()
+			return data, ErrClosed
+		}
+
+		changed := queue.changed
+		var fired chan struct{}
+		var timer Timer
+		if heap.num > 0 {
+			fired = make(chan struct{})
+			timer = heap.clock.AfterFunc(queue.deadlines[heap.min.data].Sub(now), func() { close(fired) })
+		}
+		heap.unlock()
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-changed:
+		case <-fired:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+}
+
+// Close closes the queue, releasing every goroutine blocked in WaitNext once no value is due.
+// Returns ErrClosed if the queue was already closed.
+func (queue *DelayQueue[t]) Close() error {
+	heap := queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	heap.closed = true
+	queue.notify()
+	return nil
+}
+
+// popReady extracts the minimum if it is due at or before now. The heap lock must be held.
+func (queue *DelayQueue[t]) popReady(now time.Time) (data t, ok bool) {
+	heap := queue.heap
+	if heap.num == 0 || queue.deadlines[heap.min.data].After(now) {
+		return data, false
+	}
+
+	min := heap.extractMin()
+	delete(queue.deadlines, min.data)
+	return min.data, true
+}
+
+// notify wakes every goroutine in WaitNext so it can look at the new earliest deadline. The heap lock must be held.
+func (queue *DelayQueue[t]) notify() {
+	close(queue.changed)
+	queue.changed = make(chan struct{})
+}
@@ -0,0 +1,121 @@
+package fibheap_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tests of delayQueue", func() {
+	var (
+		queue *fibheap.DelayQueue[string]
+		clock *fakeClock
+	)
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		queue = fibheap.NewDelayQueue[string]()
+		queue.SetClock(clock)
+	})
+
+	AfterEach(func() {
+		queue = nil
+	})
+
+	It("Given a delayQueue with scheduled values, when call PopReady api, it should return only the values that are due, earliest first.", func() {
+		now := clock.Now()
+		queue.Schedule("b", now.Add(2*time.Second))
+		queue.Schedule("a", now.Add(time.Second))
+		queue.Schedule("c", now.Add(3*time.Second))
+		Expect(queue.Schedule("a", now)).Should(HaveOccurred())
+
+		Expect(queue.PopReady(now)).Should(BeEmpty())
+		Expect(queue.PopReady(now.Add(2 * time.Second))).Should(Equal([]string{"a", "b"}))
+		Expect(queue.Num()).Should(BeEquivalentTo(1))
+	})
+
+	It("Given a delayQueue with scheduled values, when call Reschedule and Cancel apis, it should move and remove deadlines.", func() {
+		now := clock.Now()
+		queue.Schedule("a", now.Add(time.Second))
+		queue.Schedule("b", now.Add(2*time.Second))
+		queue.Schedule("c", now.Add(3*time.Second))
+
+		Expect(queue.Reschedule("a", now.Add(4*time.Second))).ShouldNot(HaveOccurred())
+		Expect(queue.Reschedule("c", now.Add(500*time.Millisecond))).ShouldNot(HaveOccurred())
+		Expect(queue.Cancel("b")).ShouldNot(HaveOccurred())
+		Expect(queue.Cancel("b")).Should(HaveOccurred())
+		Expect(queue.Reschedule("b", now)).Should(HaveOccurred())
+
+		deadline, ok := queue.NextDeadline()
+		Expect(ok).Should(BeTrue())
+		Expect(deadline).Should(Equal(now.Add(500 * time.Millisecond)))
+		Expect(queue.PopReady(now.Add(time.Hour))).Should(Equal([]string{"c", "a"}))
+
+		_, ok = queue.NextDeadline()
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("Given a delayQueue with a future value, when call WaitNext api, it should sleep until the deadline.", func() {
+		queue.Schedule("a", clock.Now().Add(time.Minute))
+
+		done := make(chan string)
+		go func() {
+			defer GinkgoRecover()
+			data, err := queue.WaitNext(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			done <- data
+		}()
+
+		Eventually(clock.Pending).Should(Equal(1))
+		clock.Advance(59 * time.Second)
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		clock.Advance(time.Second)
+		Eventually(done).Should(Receive(Equal("a")))
+	})
+
+	It("Given a delayQueue blocked in WaitNext, when an earlier value is scheduled, it should wait for the new deadline instead.", func() {
+		queue.Schedule("late", clock.Now().Add(time.Hour))
+
+		done := make(chan string)
+		go func() {
+			defer GinkgoRecover()
+			data, err := queue.WaitNext(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			done <- data
+		}()
+
+		Eventually(clock.Scheduled).Should(Equal(1))
+		queue.Schedule("early", clock.Now().Add(time.Second))
+		Eventually(clock.Scheduled).Should(Equal(2))
+		Expect(clock.Pending()).Should(Equal(1))
+		clock.Advance(time.Second)
+		Eventually(done).Should(Receive(Equal("early")))
+		Expect(queue.Num()).Should(BeEquivalentTo(1))
+	})
+
+	It("Given an empty delayQueue blocked in WaitNext, when the context is cancelled or the queue is closed, it should return an error.", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := queue.WaitNext(ctx)
+			done <- err
+		}()
+
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		cancel()
+		Eventually(done).Should(Receive(MatchError(context.Canceled)))
+
+		go func() {
+			_, err := queue.WaitNext(context.Background())
+			done <- err
+		}()
+
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		Expect(queue.Close()).ShouldNot(HaveOccurred())
+		Eventually(done).Should(Receive(MatchError(fibheap.ErrClosed)))
+	})
+})
@@ -2,76 +2,177 @@ package fibheap
 
 import (
 	"bytes"
-	"container/list"
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"sync"
 )
 
+// ErrClosed is returned by operations on a heap that has been closed.
+var ErrClosed = errors.New("Heap is closed")
+
 // NewFibHeap creates an initialized Fibonacci Heap.
 func NewFibHeap[t any]() *FibHeap[t] {
 	// Create a new instance of FibHeap
 	heap := new(FibHeap[t])
 	// Initialize the roots list
-	heap.roots = list.New()
+	heap.roots = nil
 	// Initialize the index map
 	heap.index = make(map[interface{}]*node[t])
-	// Initialize the treeDegrees map
-	heap.treeDegrees = make(map[uint]*list.Element)
+	// Initialize the treeDegrees table
+	heap.treeDegrees = make([]*node[t], 0)
 	// Initialize the number of values in the heap
 	heap.num = 0
 	// Initialize the minimum node
 	heap.min = nil
 	// Initialize the mutex for thread-safety
 	heap.mutex = sync.Mutex{}
+	// Initialize the condition used to wake blocked consumers
+	heap.cond = sync.NewCond(&heap.mutex)
+	// Initialize the condition used to wake blocked producers
+	heap.space = sync.NewCond(&heap.mutex)
+	// Initialize the clock used by time-based features
+	heap.clock = systemClock{}
+	// Initialize the leases map
+	heap.leases = make(map[interface{}]*lease[t])
+	// Initialize the retry attempts map
+	heap.attempts = make(map[interface{}]*retryState)
+	// Initialize the context bindings map
+	heap.bound = make(map[interface{}]*binding)
 
 	return heap
 }
 
 // Num returns the total number of values in the heap.
 func (heap *FibHeap[t]) Num() uint {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	return heap.num
 }
 
 // Insert inserts a new value with the given data and priority into the heap.
+// If the heap is full, the configured OverflowPolicy applies.
 // Returns an error if the insertion fails.
 func (heap *FibHeap[t]) Insert(data t, priority float64) error {
-	return heap.insert(data, priority)
+	return heap.InsertWait(context.Background(), data, priority)
 }
 
 // Minimum returns the current minimum data and priority in the heap.
 // Returns -inf if the heap is empty.
 func (heap *FibHeap[t]) Minimum() (data t, f float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
 	if heap.num == 0 {
 		return data, math.Inf(-1)
 	}
 
-	return heap.min.data, heap.min.priority
+	return heap.min.data, heap.fromKey(heap.min.priority)
 }
 
 // ExtractMin returns the current minimum data and priority in the heap and then extracts them from the heap.
 // Returns nil/-inf if the heap is empty.
 func (heap *FibHeap[t]) ExtractMin() (data t, f float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
 	if heap.num == 0 {
 		return data, math.Inf(-1)
 	}
 
 	min := heap.extractMin()
-	return min.data, min.priority
+	return min.data, heap.fromKey(min.priority)
+}
+
+// ExtractMinWait blocks until the heap holds at least one value, then extracts and returns the minimum.
+// Returns the context error if ctx is done first, or ErrClosed once the heap is closed and empty.
+func (heap *FibHeap[t]) ExtractMinWait(ctx context.Context) (data t, f float64, err error) {
+	stop := context.AfterFunc(ctx, func() {
+		heap.mutex.Lock()
+		defer heap.mutex.Unlock()
+		heap.cond.Broadcast()
+	})
+	defer stop()
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	for heap.expire(); heap.num == 0; heap.expire() {
+		if heap.closed {
+			return data, math.Inf(-1), ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return data, math.Inf(-1), err
+		}
+		heap.cond.Wait()
+	}
+
+	min := heap.extractMin()
+	return min.data, heap.fromKey(min.priority), nil
+}
+
+// Close marks the heap as closed and releases every goroutine blocked in ExtractMinWait.
+// Values already in the heap can still be extracted, but further inserts return ErrClosed.
+// Returns ErrClosed if the heap was already closed.
+func (heap *FibHeap[t]) Close() error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	heap.closed = true
+	heap.cond.Broadcast()
+	heap.space.Broadcast()
+	return nil
 }
 
 // Union merges the input heap into the target heap.
-// Returns an error if any duplicate data are found in the target heap.
+// Returns an error if any duplicate data are found in the target heap,
+// or ErrFull if a bounded heap without the Evict policy cannot take every value.
+// With the Evict policy, values that do not make the cut are dropped.
 func (heap *FibHeap[t]) Union(anotherHeap *FibHeap[t]) error {
-	for data := range anotherHeap.index {
-		if _, exists := heap.index[data]; exists {
+	items := anotherHeap.items()
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	for _, item := range items {
+		if heap.validate(item.data, item.priority) != nil {
 			return errors.New("Duplicate data is found in the target heap")
 		}
 	}
 
-	for _, node := range anotherHeap.index {
-		heap.insert(node.data, node.priority)
+	if heap.policy != Evict {
+		size := 0
+		for _, item := range items {
+			size += heap.sizeOf(item.data)
+		}
+		if heap.full(uint(len(items)), size) {
+			return ErrFull
+		}
+	}
+
+	for _, item := range items {
+		key := heap.toKey(item.priority)
+		if heap.makeRoom(context.Background(), key, heap.sizeOf(item.data)) == nil {
+			heap.insert(item.data, key)
+		}
+	}
+
+	if len(items) > 0 {
+		heap.cond.Broadcast()
 	}
 
 	return nil
@@ -84,8 +185,11 @@ func (heap *FibHeap[t]) DecreasePriority(data t, priority float64) error {
 		return errors.New("Negative infinity priority is reserved for internal usage")
 	}
 
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if node, exists := heap.index[data]; exists {
-		return heap.decreaseKey(node, priority)
+		return heap.decreaseKey(node, heap.toKey(priority))
 	}
 
 	return errors.New("Value is not found")
@@ -98,21 +202,70 @@ func (heap *FibHeap[t]) IncreasePriority(data t, priority float64) error {
 		return errors.New("Negative infinity priority is reserved for internal usage")
 	}
 
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if node, exists := heap.index[data]; exists {
-		return heap.increaseKey(node, priority)
+		return heap.increaseKey(node, heap.toKey(priority))
 	}
 
 	return errors.New("Value is not found")
 }
 
+// CompareAndSetPriority sets the priority of the value with the given data to priority,
+// but only if its current priority equals expected. The new priority may be smaller or larger.
+// It is not supported with linear aging, under which the current priority changes between any two calls;
+// step aging is fine, as priorities only change at interval boundaries.
+// Returns whether the priority was set, and an error if the value is not found, the priority is negative infinity
+// or NaN, or linear aging is on.
+func (heap *FibHeap[t]) CompareAndSetPriority(data t, expected, priority float64) (swapped bool, err error) {
+	if math.IsInf(priority, -1) {
+		return false, errors.New("Negative infinity priority is reserved for internal usage")
+	}
+	if math.IsNaN(priority) {
+		return false, errors.New("NaN priority is not allowed ")
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.agingRate != 0 && heap.agingInterval == 0 {
+		return false, errors.New("CompareAndSetPriority is not supported with linear aging")
+	}
+
+	node, exists := heap.index[data]
+	if !exists {
+		return false, errors.New("Value is not found")
+	}
+
+	// Both conversions use the same sample of the credit, so that a step boundary cannot fall between them.
+	credit := heap.credit()
+	if node.priority-credit+heap.offset != expected {
+		return false, nil
+	}
+
+	switch key := priority + credit - heap.offset; {
+	case key < node.priority:
+		err = heap.decreaseKey(node, key)
+	case key > node.priority:
+		err = heap.increaseKey(node, key)
+	}
+
+	return err == nil, err
+}
+
 // Delete removes the value with the given data from the heap.
 // Returns an error if the data is not found.
 func (heap *FibHeap[t]) Delete(data t) error {
-	if _, exists := heap.index[data]; !exists {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	node, exists := heap.index[data]
+	if !exists {
 		return errors.New("Tag is not found")
 	}
 
-	heap.Extract(data)
+	heap.deleteNode(node)
 
 	return nil
 }
@@ -120,8 +273,11 @@ func (heap *FibHeap[t]) Delete(data t) error {
 // GetPriority returns the priority of the value with the given data in the heap.
 // Returns -inf if the value is not found.
 func (heap *FibHeap[t]) GetPriority(data t) (priority float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if node, exists := heap.index[data]; exists {
-		return node.priority
+		return heap.fromKey(node.priority)
 	}
 
 	return math.Inf(-1)
@@ -130,8 +286,11 @@ func (heap *FibHeap[t]) GetPriority(data t) (priority float64) {
 // ExtractPriority returns the priority of the value with the given data in the heap and then extracts it from the heap.
 // Returns -inf if the value is not found.
 func (heap *FibHeap[t]) ExtractPriority(data t) (priority float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if node, exists := heap.index[data]; exists {
-		priority = node.priority
+		priority = heap.fromKey(node.priority)
 		heap.deleteNode(node)
 		return
 	}
@@ -142,8 +301,11 @@ func (heap *FibHeap[t]) ExtractPriority(data t) (priority float64) {
 // ExtractValue returns the data and priority of the value with the given data in the heap and then extracts it from the heap.
 // Returns the original data and -inf if the value is not found.
 func (heap *FibHeap[t]) Extract(data t) (t, float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if node, exists := heap.index[data]; exists {
-		k := node.priority
+		k := heap.fromKey(node.priority)
 		v := node.data
 		heap.deleteNode(node)
 		return v, k
@@ -159,15 +321,18 @@ func (heap *FibHeap[t]) Extract(data t) (t, float64) {
 func (heap *FibHeap[t]) Stats() string {
 	var buffer bytes.Buffer
 
+	heap.mutex.Lock()
+	defer heap.unlock()
+
 	if heap.num == 0 {
 		buffer.WriteString(fmt.Sprintf("Heap is empty.\n"))
 		return buffer.String()
 	}
 
-	buffer.WriteString(fmt.Sprintf("Total number: %d, Root Size: %d, Index size: %d,\n", heap.num, heap.roots.Len(), len(heap.index)))
-	buffer.WriteString(fmt.Sprintf("Current min: priority(%f), data(%v),\n", heap.min.priority, heap.min.data))
+	buffer.WriteString(fmt.Sprintf("Total number: %d, Root Size: %d, Index size: %d,\n", heap.num, length(heap.roots), len(heap.index)))
+	buffer.WriteString(fmt.Sprintf("Current min: priority(%f), data(%v),\n", heap.fromKey(heap.min.priority), heap.min.data))
 	buffer.WriteString(fmt.Sprintf("Heap detail:\n"))
-	probeTree[t](&buffer, heap.roots)
+	heap.probeTree(&buffer, heap.roots)
 	buffer.WriteString(fmt.Sprintf("\n"))
 	return buffer.String()
 }
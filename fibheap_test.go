@@ -1,12 +1,20 @@
 package fibheap_test
 
 import (
+	"bytes"
 	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/JustinTimperio/fibheap"
 
@@ -79,6 +87,73 @@ func BenchmarkStandardLibHeap(b *testing.B) {
 	wg.Wait()
 }
 
+func BenchmarkInsert(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		heap.Insert(i, rand.Float64())
+	}
+}
+
+func BenchmarkInsertExtractMin(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	for i := 0; i < 10000; i++ {
+		heap.Insert(i, rand.Float64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		heap.Insert(10000+i, rand.Float64())
+		heap.ExtractMin()
+	}
+}
+
+func BenchmarkInsertExtractMinPooled(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	heap.SetNodePool(1024)
+	for i := 0; i < 10000; i++ {
+		heap.Insert(i, rand.Float64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		heap.Insert(10000+i, rand.Float64())
+		heap.ExtractMin()
+	}
+}
+
+func BenchmarkExtractMin(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	for i := 0; i < b.N; i++ {
+		heap.Insert(i, rand.Float64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		heap.ExtractMin()
+	}
+}
+
+func BenchmarkDecreasePriority(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	for i := 0; i < 100000; i++ {
+		heap.Insert(i, float64(i))
+	}
+	heap.ExtractMin()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data := 1 + i%99999
+		heap.DecreasePriority(data, heap.GetPriority(data)-100000)
+	}
+}
+
 func TestBasic(t *testing.T) {
 
 	heap := fibheap.NewFibHeap[SchoolEntry]()
@@ -274,6 +349,77 @@ var _ = Describe("Tests of fibHeap", func() {
 		})
 	})
 
+	Context("compare and set tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with a value, when call CompareAndSetPriority api, it should only set the priority if the expected one matches.", func() {
+			heap.Insert(1, 10)
+			heap.Insert(2, 20)
+
+			swapped, err := heap.CompareAndSetPriority(1, 11, 30)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swapped).Should(BeFalse())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(10))
+
+			swapped, err = heap.CompareAndSetPriority(1, 10, 30)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swapped).Should(BeTrue())
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(2))
+
+			swapped, err = heap.CompareAndSetPriority(1, 30, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swapped).Should(BeTrue())
+			data, _ = heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(1))
+
+			swapped, err = heap.CompareAndSetPriority(1, 5, 5)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swapped).Should(BeTrue())
+		})
+
+		It("Given a fibHeap, when call CompareAndSetPriority api with a non-exists value or a negative infinity or NaN priority, it should return error.", func() {
+			heap.Insert(1, 10)
+
+			_, err := heap.CompareAndSetPriority(2, 10, 5)
+			Expect(err).Should(HaveOccurred())
+			_, err = heap.CompareAndSetPriority(1, 10, math.Inf(-1))
+			Expect(err).Should(HaveOccurred())
+			swapped, err := heap.CompareAndSetPriority(1, 10, math.NaN())
+			Expect(err).Should(HaveOccurred())
+			Expect(swapped).Should(BeFalse())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(10))
+		})
+
+		It("Given a fibHeap shared by many goroutines, when they bump a priority with CompareAndSetPriority, it should not lose updates.", func() {
+			heap.Insert(1, 0)
+			var wg sync.WaitGroup
+			for w := 0; w < 8; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 100; i++ {
+						for {
+							current := heap.GetPriority(1)
+							if swapped, _ := heap.CompareAndSetPriority(1, current, current+1); swapped {
+								break
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(800))
+		})
+	})
+
 	Context("union tests", func() {
 		BeforeEach(func() {
 			heap = fibheap.NewFibHeap[int]()
@@ -486,52 +632,1424 @@ var _ = Describe("Tests of fibHeap", func() {
 			Expect(priority).Should(BeEquivalentTo(math.Inf(1)))
 		})
 	})
-})
 
-// An Item is something we manage in a priority queue.
-type Item struct {
-	value    string // The value of the item; arbitrary.
-	priority int    // The priority of the item in the queue.
-	// The index is needed by update and is maintained by the heap.Interface methods.
-	index int // The index of the item in the heap.
-}
+	Context("blocking tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+		})
 
-// A PriorityQueue implements heap.Interface and holds Items.
-type PriorityQueue []*Item
+		AfterEach(func() {
+			heap = nil
+		})
 
-func (pq PriorityQueue) Len() int { return len(pq) }
+		It("Given a fibHeap with values, when call ExtractMinWait api, it should return the minimum value without blocking.", func() {
+			heap.Insert(1, 10)
+			heap.Insert(2, 5)
 
-func (pq PriorityQueue) Less(i, j int) bool {
-	// We want Pop to give us the highest, not lowest, priority so we use greater than here.
-	return pq[i].priority > pq[j].priority
-}
+			data, priority, err := heap.ExtractMinWait(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(BeEquivalentTo(2))
+			Expect(priority).Should(BeEquivalentTo(5))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
 
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
-}
+		It("Given an empty fibHeap, when a value is inserted while ExtractMinWait is blocked, it should wake up and return the value.", func() {
+			done := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				data, _, err := heap.ExtractMinWait(context.Background())
+				Expect(err).ShouldNot(HaveOccurred())
+				done <- data
+			}()
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			Expect(heap.Insert(7, 1)).ShouldNot(HaveOccurred())
+			Eventually(done).Should(Receive(BeEquivalentTo(7)))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+		})
 
-func (pq *PriorityQueue) Push(x any) {
-	n := len(*pq)
-	item := x.(*Item)
-	item.index = n
-	*pq = append(*pq, item)
-}
+		It("Given an empty fibHeap, when another heap is unioned while ExtractMinWait is blocked, it should wake up and return the value.", func() {
+			anotherHeap = fibheap.NewFibHeap[int]()
+			anotherHeap.Insert(3, 3)
+
+			done := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				data, _, err := heap.ExtractMinWait(context.Background())
+				Expect(err).ShouldNot(HaveOccurred())
+				done <- data
+			}()
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			Expect(heap.Union(anotherHeap)).ShouldNot(HaveOccurred())
+			Eventually(done).Should(Receive(BeEquivalentTo(3)))
+		})
 
-func (pq *PriorityQueue) Pop() any {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	old[n-1] = nil  // avoid memory leak
-	item.index = -1 // for safety
-	*pq = old[0 : n-1]
-	return item
-}
+		It("Given an empty fibHeap, when the context is cancelled while ExtractMinWait is blocked, it should return the context error.", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				_, _, err := heap.ExtractMinWait(ctx)
+				done <- err
+			}()
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			cancel()
+			Eventually(done).Should(Receive(MatchError(context.Canceled)))
+		})
 
-// update modifies the priority and value of an Item in the queue.
-func (pq *PriorityQueue) update(item *Item, value string, priority int) {
-	item.value = value
-	item.priority = priority
-	heap.Fix(pq, item.index)
+		It("Given an empty fibHeap with blocked consumers, when call Close api, it should release every consumer with ErrClosed.", func() {
+			done := make(chan error, 3)
+			for i := 0; i < 3; i++ {
+				go func() {
+					_, _, err := heap.ExtractMinWait(context.Background())
+					done <- err
+				}()
+			}
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			Expect(heap.Close()).ShouldNot(HaveOccurred())
+			for i := 0; i < 3; i++ {
+				Eventually(done).Should(Receive(MatchError(fibheap.ErrClosed)))
+			}
+		})
+
+		It("Given a closed fibHeap with values, when call ExtractMinWait and Insert apis, it should drain the values and then reject inserts.", func() {
+			heap.Insert(1, 1)
+			Expect(heap.Close()).ShouldNot(HaveOccurred())
+			Expect(heap.Close()).Should(MatchError(fibheap.ErrClosed))
+			Expect(heap.Insert(2, 2)).Should(MatchError(fibheap.ErrClosed))
+
+			data, _, err := heap.ExtractMinWait(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(BeEquivalentTo(1))
+
+			_, _, err = heap.ExtractMinWait(context.Background())
+			Expect(err).Should(MatchError(fibheap.ErrClosed))
+		})
+	})
+
+	Context("capacity tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a full fibHeap with the Reject policy, when call Insert api, it should return ErrFull.", func() {
+			heap.SetCapacity(3, fibheap.Reject)
+			for i := 0; i < 3; i++ {
+				Expect(heap.Insert(i, float64(i))).ShouldNot(HaveOccurred())
+			}
+
+			Expect(heap.Insert(3, 3)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Num()).Should(BeEquivalentTo(3))
+
+			heap.ExtractMin()
+			Expect(heap.Insert(3, 3)).ShouldNot(HaveOccurred())
+		})
+
+		It("Given a full fibHeap with the Block policy, when call Insert api, it should wait until a value is extracted.", func() {
+			heap.SetCapacity(1, fibheap.Block)
+			heap.Insert(1, 1)
+
+			done := make(chan error)
+			go func() {
+				done <- heap.Insert(2, 2)
+			}()
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			data, _ := heap.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(1))
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a full fibHeap with the Block policy, when the context of InsertWait is cancelled, it should return the context error.", func() {
+			heap.SetCapacity(1, fibheap.Block)
+			heap.Insert(1, 1)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- heap.InsertWait(ctx, 2, 2)
+			}()
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			cancel()
+			Eventually(done).Should(Receive(MatchError(context.Canceled)))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a fibHeap with the Evict policy, when Insert more values than its capacity, it should keep the values with the smallest priorities.", func() {
+			heap.SetCapacity(10, fibheap.Evict)
+			for i := 0; i < 1000; i++ {
+				heap.Insert(i, float64(rand.Intn(1000000)))
+			}
+			Expect(heap.Num()).Should(BeEquivalentTo(10))
+
+			_, max := heap.Maximum()
+			Expect(heap.Insert(1000, max)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Insert(1001, -1)).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(10))
+
+			_, lastKey := heap.ExtractMin()
+			Expect(lastKey).Should(BeEquivalentTo(-1))
+			for heap.Num() > 0 {
+				_, priority := heap.ExtractMin()
+				Expect(priority).Should(BeNumerically(">=", lastKey))
+				Expect(priority).Should(BeNumerically("<", max))
+				lastKey = priority
+			}
+		})
+
+		It("Given a fibHeap with values, when call SetCapacity api with the Evict policy, it should evict the values with the largest priorities.", func() {
+			for i := 0; i < 100; i++ {
+				heap.Insert(i, float64(i))
+			}
+			heap.DecreasePriority(99, -1)
+			heap.IncreasePriority(0, 1000)
+
+			heap.SetCapacity(5, fibheap.Evict)
+			Expect(heap.Num()).Should(BeEquivalentTo(5))
+			data, priority := heap.Maximum()
+			Expect(data).Should(BeEquivalentTo(4))
+			Expect(priority).Should(BeEquivalentTo(4))
+			Expect(heap.GetPriority(99)).Should(BeEquivalentTo(-1))
+		})
+
+		It("Given a fibHeap with a budget, when values are inserted, extracted, deleted and unioned, it should track their total weight.", func() {
+			heap.SetBudget(func(i int) int { return i }, 1000, fibheap.Reject)
+			heap.Insert(10, 1)
+			heap.Insert(20, 2)
+			heap.Insert(30, 3)
+			Expect(heap.Weight()).Should(BeEquivalentTo(60))
+
+			heap.ExtractMin()
+			Expect(heap.Weight()).Should(BeEquivalentTo(50))
+			heap.Delete(30)
+			Expect(heap.Weight()).Should(BeEquivalentTo(20))
+
+			anotherHeap = fibheap.NewFibHeap[int]()
+			anotherHeap.Insert(40, 4)
+			anotherHeap.Insert(50, 5)
+			Expect(heap.Union(anotherHeap)).ShouldNot(HaveOccurred())
+			Expect(heap.Weight()).Should(BeEquivalentTo(110))
+		})
+
+		It("Given a fibHeap with a budget and the Reject policy, when a value does not fit, it should return ErrFull.", func() {
+			heap.SetBudget(func(i int) int { return i }, 100, fibheap.Reject)
+			Expect(heap.Insert(60, 1)).ShouldNot(HaveOccurred())
+			Expect(heap.Insert(50, 2)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Insert(40, 2)).ShouldNot(HaveOccurred())
+			Expect(heap.Weight()).Should(BeEquivalentTo(100))
+
+			anotherHeap = fibheap.NewFibHeap[int]()
+			anotherHeap.Insert(1, 1)
+			Expect(heap.Union(anotherHeap)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+		})
+
+		It("Given a fibHeap with a budget and the Evict policy, when a heavy value is inserted, it should evict values with the largest priorities until it fits.", func() {
+			heap.SetBudget(func(i int) int { return i }, 100, fibheap.Evict)
+			heap.Insert(30, 1)
+			heap.Insert(31, 3)
+			heap.Insert(32, 4)
+
+			Expect(heap.Insert(60, 2)).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+			Expect(heap.Weight()).Should(BeEquivalentTo(90))
+			Expect(heap.GetPriority(31)).Should(BeEquivalentTo(math.Inf(-1)))
+			Expect(heap.GetPriority(32)).Should(BeEquivalentTo(math.Inf(-1)))
+
+			Expect(heap.Insert(101, 0)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Weight()).Should(BeEquivalentTo(90))
+		})
+	})
+
+	Context("transaction tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+			for i := 0; i < 100; i++ {
+				heap.Insert(i, float64(i))
+			}
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap, when fn of Update api succeeds, it should apply every operation.", func() {
+			err := heap.Update(func(tx *fibheap.Tx[int]) error {
+				data, _ := tx.ExtractMin()
+				Expect(data).Should(BeEquivalentTo(0))
+				Expect(tx.DecreasePriority(50, -1)).ShouldNot(HaveOccurred())
+				Expect(tx.Insert(100, 0.5)).ShouldNot(HaveOccurred())
+				Expect(tx.Delete(99)).ShouldNot(HaveOccurred())
+				Expect(tx.Num()).Should(BeEquivalentTo(99))
+				return nil
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(99))
+			Expect(heap.GetPriority(0)).Should(BeEquivalentTo(math.Inf(-1)))
+			Expect(heap.GetPriority(99)).Should(BeEquivalentTo(math.Inf(-1)))
+			Expect(heap.GetPriority(100)).Should(BeEquivalentTo(0.5))
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(50))
+		})
+
+		It("Given a fibHeap, when fn of Update api returns an error, it should roll back every operation.", func() {
+			rollback := fmt.Errorf("rollback")
+			err := heap.Update(func(tx *fibheap.Tx[int]) error {
+				for i := 0; i < 10; i++ {
+					tx.ExtractMin()
+				}
+				tx.DecreasePriority(50, -1)
+				tx.IncreasePriority(60, 1000)
+				tx.Insert(100, 0.5)
+				tx.DecreasePriority(100, -2)
+				tx.Delete(99)
+				tx.Delete(50)
+				return rollback
+			})
+
+			Expect(err).Should(MatchError(rollback))
+			Expect(heap.Num()).Should(BeEquivalentTo(100))
+			Expect(heap.GetPriority(100)).Should(BeEquivalentTo(math.Inf(-1)))
+			for i := 0; i < 100; i++ {
+				data, priority := heap.ExtractMin()
+				Expect(data).Should(BeEquivalentTo(i))
+				Expect(priority).Should(BeEquivalentTo(i))
+			}
+		})
+
+		It("Given a fibHeap, when fn of Update api panics, it should roll back every operation and propagate the panic.", func() {
+			Expect(func() {
+				heap.Update(func(tx *fibheap.Tx[int]) error {
+					tx.ExtractMin()
+					tx.Insert(100, -1)
+					panic("boom")
+				})
+			}).Should(PanicWith("boom"))
+
+			Expect(heap.Num()).Should(BeEquivalentTo(100))
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(0))
+			Expect(heap.GetPriority(100)).Should(BeEquivalentTo(math.Inf(-1)))
+		})
+
+		It("Given a full fibHeap with the Evict policy, when fn of Update api returns an error, it should restore the evicted values.", func() {
+			heap.SetCapacity(100, fibheap.Evict)
+			err := heap.Update(func(tx *fibheap.Tx[int]) error {
+				for i := 100; i < 110; i++ {
+					Expect(tx.Insert(i, -float64(i))).ShouldNot(HaveOccurred())
+				}
+				Expect(tx.Num()).Should(BeEquivalentTo(100))
+				return fmt.Errorf("rollback")
+			})
+
+			Expect(err).Should(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(100))
+			data, priority := heap.Maximum()
+			Expect(data).Should(BeEquivalentTo(99))
+			Expect(priority).Should(BeEquivalentTo(99))
+		})
+	})
+
+	Context("hook tests", func() {
+		var events []string
+
+		BeforeEach(func() {
+			events = nil
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnInsert: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("insert %d %v", data, priority))
+				},
+				OnExtract: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("extract %d %v", data, priority))
+				},
+				OnPriorityChange: func(data int, old, priority float64) {
+					events = append(events, fmt.Sprintf("change %d %v %v", data, old, priority))
+				},
+				OnDelete: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("delete %d %v", data, priority))
+				},
+				OnMinChange: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("min %d %v", data, priority))
+				},
+			})
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with hooks, when values are inserted, reprioritized, deleted and extracted, it should fire the matching hooks.", func() {
+			heap.Insert(1, 10)
+			heap.Insert(2, 20)
+			heap.DecreasePriority(2, 5)
+			heap.IncreasePriority(1, 15)
+			heap.Delete(1)
+			heap.ExtractMin()
+
+			Expect(events).Should(Equal([]string{
+				"insert 1 10", "min 1 10",
+				"insert 2 20",
+				"change 2 20 5", "min 2 5",
+				"change 1 10 15",
+				"delete 1 15",
+				"extract 2 5", "min 0 -Inf",
+			}))
+		})
+
+		It("Given a fibHeap with hooks, when fn of Update api returns an error, it should not fire any hook.", func() {
+			heap.Insert(1, 10)
+			events = nil
+
+			heap.Update(func(tx *fibheap.Tx[int]) error {
+				tx.Insert(2, 1)
+				tx.ExtractMin()
+				return fmt.Errorf("rollback")
+			})
+
+			Expect(events).Should(BeEmpty())
+		})
+
+		It("Given a fibHeap with hooks calling back into it, when a hook fires, it should not deadlock.", func() {
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnInsert: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("num %d", heap.Num()))
+				},
+			})
+
+			heap.Insert(1, 10)
+			heap.Insert(2, 20)
+			Expect(events).Should(Equal([]string{"num 1", "num 2"}))
+		})
+
+		It("Given a fibHeap with a panicking hook, when the hook fires, it should re-raise the panic and leave the heap intact.", func() {
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnInsert: func(data int, priority float64) {
+					panic("boom")
+				},
+				OnMinChange: func(data int, priority float64) {
+					events = append(events, fmt.Sprintf("min %d %v", data, priority))
+				},
+			})
+
+			Expect(func() { heap.Insert(1, 10) }).Should(PanicWith("boom"))
+			Expect(events).Should(Equal([]string{"min 1 10"}))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(heap.DecreasePriority(1, 5)).Should(Succeed())
+			data, priority := heap.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(1))
+			Expect(priority).Should(BeEquivalentTo(5))
+		})
+
+		It("Given a fibHeap with panicking hooks, when a lease expires or a bound context is cancelled, it should drop the panic and still apply the change.", func() {
+			clock := newFakeClock()
+			heap.SetClock(clock)
+			heap.Insert(1, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+			heap.InsertCtx(ctx, 2, 20)
+			heap.Lease(time.Second)
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnInsert: func(int, float64) { panic("boom") },
+				OnDelete: func(int, float64) { panic("boom") },
+			})
+
+			Expect(func() { clock.Advance(time.Second) }).ShouldNot(Panic())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(10))
+
+			cancel()
+			Eventually(func() float64 { return heap.GetPriority(2) }).Should(BeEquivalentTo(math.Inf(-1)))
+		})
+	})
+
+	Context("lease tests", func() {
+		var clock *fakeClock
+
+		BeforeEach(func() {
+			clock = newFakeClock()
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetClock(clock)
+			heap.Insert(1, 1)
+			heap.Insert(2, 2)
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap, when call Lease and Ack apis, it should hide the minimum and then remove it for good.", func() {
+			data, priority := heap.Lease(time.Minute)
+			Expect(data).Should(BeEquivalentTo(1))
+			Expect(priority).Should(BeEquivalentTo(1))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(heap.Insert(1, 5)).Should(HaveOccurred())
+
+			Expect(heap.Ack(1)).ShouldNot(HaveOccurred())
+			Expect(heap.Ack(1)).Should(HaveOccurred())
+			clock.Advance(time.Hour)
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(heap.Insert(1, 5)).ShouldNot(HaveOccurred())
+		})
+
+		It("Given a fibHeap with a leased value, when call Nack api, it should put the value back with the new priority.", func() {
+			heap.Lease(time.Minute)
+			Expect(heap.Nack(1, 3)).ShouldNot(HaveOccurred())
+			Expect(heap.Nack(1, 3)).Should(HaveOccurred())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(3))
+
+			clock.Advance(time.Hour)
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(2))
+		})
+
+		It("Given a fibHeap with a leased value, when the lease expires, it should put the value back with its original priority.", func() {
+			heap.Lease(time.Minute)
+			clock.Advance(59 * time.Second)
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+
+			clock.Advance(time.Second)
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(1))
+			Expect(heap.Ack(1)).Should(HaveOccurred())
+		})
+
+		It("Given a fibHeap with a leased expiring value, when the lease expires or is nacked, it should keep the deadline of the value.", func() {
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetClock(clock)
+			heap.InsertWithTTL(1, 1, time.Hour)
+			heap.InsertWithTTL(2, 2, time.Hour)
+
+			heap.Lease(time.Minute)
+			clock.Advance(time.Minute)
+			data, _ := heap.Lease(time.Minute)
+			Expect(heap.Nack(data, 0)).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+
+			clock.Advance(time.Hour)
+			Expect(heap.Sweep()).Should(Equal(2))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a fibHeap with a leased context-bound value, when the lease expires and the context is cancelled, it should remove the value.", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			heap.Delete(1)
+			heap.InsertCtx(ctx, 1, 1)
+
+			heap.Lease(time.Minute)
+			clock.Advance(time.Minute)
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+
+			cancel()
+			Eventually(heap.Num).Should(BeEquivalentTo(1))
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(math.Inf(-1)))
+		})
+
+		It("Given a fibHeap with a leased value, when call Nack api with a NaN priority, it should fail and keep the lease.", func() {
+			heap.Lease(time.Minute)
+			Expect(heap.Nack(1, math.NaN())).Should(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(heap.Ack(1)).ShouldNot(HaveOccurred())
+		})
+
+		It("Given a full fibHeap with a leased value, when a value is inserted, it should count the leased value toward the capacity.", func() {
+			heap.SetCapacity(2, fibheap.Reject)
+			heap.Lease(time.Minute)
+			Expect(heap.Insert(3, 3)).Should(MatchError(fibheap.ErrFull))
+
+			Expect(heap.Nack(1, 1)).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(2))
+
+			heap.Lease(time.Minute)
+			Expect(heap.Ack(1)).ShouldNot(HaveOccurred())
+			Expect(heap.Insert(3, 3)).ShouldNot(HaveOccurred())
+		})
+
+		It("Given an evicting fibHeap full of leased values, when a value is inserted, it should reject it.", func() {
+			heap.SetCapacity(1, fibheap.Evict)
+			heap.Lease(time.Minute)
+			Expect(heap.Insert(3, 0)).Should(MatchError(fibheap.ErrFull))
+
+			clock.Advance(time.Minute)
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a closed fibHeap with leased values, when they are returned, it should not put them back.", func() {
+			heap.Lease(time.Minute)
+			heap.Lease(time.Minute)
+			heap.Close()
+
+			Expect(heap.Nack(1, 1)).Should(MatchError(fibheap.ErrClosed))
+			Expect(heap.Ack(1)).ShouldNot(HaveOccurred())
+			clock.Advance(time.Minute)
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+			Expect(heap.Ack(2)).Should(HaveOccurred())
+		})
+
+		It("Given an empty fibHeap, when call Lease api, it should return -inf.", func() {
+			heap = fibheap.NewFibHeap[int]()
+			_, priority := heap.Lease(time.Minute)
+			Expect(priority).Should(BeEquivalentTo(math.Inf(-1)))
+		})
+	})
+
+	Context("retry tests", func() {
+		var clock *fakeClock
+
+		BeforeEach(func() {
+			clock = newFakeClock()
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetClock(clock)
+			heap.SetRetryPolicy(fibheap.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     fibheap.ExponentialBackoff(time.Second, 3*time.Second),
+			})
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given an exponential backoff, when it is called for growing attempts, it should double the delay up to the maximum.", func() {
+			backoff := fibheap.ExponentialBackoff(time.Second, 5*time.Second)
+			Expect(backoff(1)).Should(Equal(time.Second))
+			Expect(backoff(2)).Should(Equal(2 * time.Second))
+			Expect(backoff(3)).Should(Equal(4 * time.Second))
+			Expect(backoff(4)).Should(Equal(5 * time.Second))
+			Expect(backoff(100)).Should(Equal(5 * time.Second))
+		})
+
+		It("Given an extracted value, when call Retry api, it should re-queue the value at now plus the backoff.", func() {
+			heap.Insert(1, 0)
+			heap.ExtractMin()
+			cause := fmt.Errorf("failed")
+
+			Expect(heap.Retry(1, cause)).ShouldNot(HaveOccurred())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(fibheap.TimePriority(clock.Now().Add(time.Second))))
+			Expect(heap.Retry(1, cause)).Should(HaveOccurred())
+
+			heap.ExtractMin()
+			Expect(heap.Retry(1, cause)).ShouldNot(HaveOccurred())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(fibheap.TimePriority(clock.Now().Add(2 * time.Second))))
+
+			attempts, lastErr := heap.Attempts(1)
+			Expect(attempts).Should(Equal(2))
+			Expect(lastErr).Should(MatchError(cause))
+		})
+
+		It("Given a leased value, when call Retry api, it should end the lease and re-queue the value.", func() {
+			heap.Insert(1, 0)
+			heap.Lease(time.Minute)
+
+			Expect(heap.Retry(1, nil)).ShouldNot(HaveOccurred())
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(heap.Ack(1)).Should(HaveOccurred())
+
+			clock.Advance(time.Hour)
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a value that exceeds the max attempts, when call Retry api, it should move the value to the dead-letter heap and allow it to be replayed.", func() {
+			heap.Insert(1, 0)
+			for i := 0; i < 3; i++ {
+				heap.ExtractMin()
+				Expect(heap.Retry(1, nil)).ShouldNot(HaveOccurred())
+			}
+
+			heap.ExtractMin()
+			Expect(heap.Retry(1, nil)).Should(MatchError(fibheap.ErrDeadLetter))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+			Expect(heap.DeadLetters().Num()).Should(BeEquivalentTo(1))
+			Expect(heap.DeadLetters().GetPriority(1)).Should(BeEquivalentTo(fibheap.TimePriority(clock.Now())))
+
+			Expect(heap.Replay(1)).ShouldNot(HaveOccurred())
+			Expect(heap.Replay(1)).Should(HaveOccurred())
+			Expect(heap.DeadLetters().Num()).Should(BeEquivalentTo(0))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			attempts, _ := heap.Attempts(1)
+			Expect(attempts).Should(Equal(0))
+		})
+
+		It("Given a bounded or closed fibHeap, when call Retry and Replay apis, it should apply the overflow policy and reject values once closed.", func() {
+			heap.SetCapacity(1, fibheap.Reject)
+			heap.Insert(1, 0)
+			heap.ExtractMin()
+			heap.Insert(2, 0)
+			Expect(heap.Retry(1, nil)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+
+			heap.Retry(1, nil)
+			heap.Retry(1, nil)
+			Expect(heap.Retry(1, nil)).Should(MatchError(fibheap.ErrDeadLetter))
+			Expect(heap.Retry(1, nil)).ShouldNot(MatchError(fibheap.ErrDeadLetter))
+			Expect(heap.Replay(1)).Should(MatchError(fibheap.ErrFull))
+			Expect(heap.DeadLetters().Num()).Should(BeEquivalentTo(1))
+
+			heap.ExtractMin()
+			heap.Close()
+			Expect(heap.Retry(2, nil)).Should(MatchError(fibheap.ErrClosed))
+			Expect(heap.Replay(1)).Should(MatchError(fibheap.ErrClosed))
+			Expect(heap.DeadLetters().Num()).Should(BeEquivalentTo(1))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+		})
+	})
+
+	Context("ttl tests", func() {
+		var (
+			clock   *fakeClock
+			expired []int
+		)
+
+		BeforeEach(func() {
+			expired = nil
+			clock = newFakeClock()
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetClock(clock)
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnExpire: func(data int, priority float64) {
+					expired = append(expired, data)
+				},
+			})
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with expiring values, when call Minimum and ExtractMin apis after the TTL, it should skip and evict the expired values.", func() {
+			heap.InsertWithTTL(1, 1, time.Second)
+			heap.InsertWithTTL(2, 2, time.Minute)
+			heap.Insert(3, 3)
+
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(1))
+
+			clock.Advance(time.Second)
+			data, _ = heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(2))
+			Expect(expired).Should(Equal([]int{1}))
+
+			clock.Advance(time.Minute)
+			data, _ = heap.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(3))
+			Expect(expired).Should(Equal([]int{1, 2}))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a fibHeap with expiring values, when call Sweep api, it should evict only the expired values.", func() {
+			for i := 0; i < 100; i++ {
+				heap.InsertWithTTL(i, float64(i), time.Duration(i+1)*time.Second)
+			}
+			heap.Insert(100, 100)
+
+			clock.Advance(10 * time.Second)
+			Expect(heap.Sweep()).Should(Equal(10))
+			Expect(heap.Sweep()).Should(Equal(0))
+			Expect(heap.Num()).Should(BeEquivalentTo(91))
+			Expect(expired).Should(HaveLen(10))
+		})
+
+		It("Given a fibHeap with an expiring value, when the value is removed before its TTL, it should stop tracking its expiry.", func() {
+			heap.InsertWithTTL(1, 1, time.Second)
+			heap.ExtractMin()
+			heap.Insert(1, 1)
+
+			clock.Advance(time.Minute)
+			Expect(heap.Sweep()).Should(Equal(0))
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+			Expect(expired).Should(BeEmpty())
+		})
+
+		It("Given a fibHeap with an expiring value, when fn of Update api extracts it and returns an error, it should restore its expiry.", func() {
+			heap.InsertWithTTL(1, 1, time.Second)
+			heap.Update(func(tx *fibheap.Tx[int]) error {
+				tx.ExtractMin()
+				return fmt.Errorf("rollback")
+			})
+
+			clock.Advance(time.Second)
+			Expect(heap.Sweep()).Should(Equal(1))
+			Expect(expired).Should(Equal([]int{1}))
+		})
+	})
+
+	Context("aging tests", func() {
+		var clock *fakeClock
+
+		BeforeEach(func() {
+			clock = newFakeClock()
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetClock(clock)
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with linear aging, when time passes, it should improve priorities by the rate.", func() {
+			heap.SetAging(2, 0)
+			heap.Insert(1, 100)
+			clock.Advance(10 * time.Second)
+			heap.Insert(2, 100)
+
+			Expect(heap.GetPriority(1)).Should(BeNumerically("~", 80, 1e-9))
+			Expect(heap.GetPriority(2)).Should(BeNumerically("~", 100, 1e-9))
+			data, priority := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(1))
+			Expect(priority).Should(BeNumerically("~", 80, 1e-9))
+
+			Expect(heap.DecreasePriority(2, 50)).ShouldNot(HaveOccurred())
+			clock.Advance(5 * time.Second)
+			Expect(heap.GetPriority(2)).Should(BeNumerically("~", 40, 1e-9))
+		})
+
+		It("Given a fibHeap with aging, when call CompareAndSetPriority api, it should fail with linear aging and match within a step.", func() {
+			heap.Insert(1, 100)
+
+			heap.SetAging(1, 0)
+			_, err := heap.CompareAndSetPriority(1, 100, 50)
+			Expect(err).Should(HaveOccurred())
+
+			heap.SetAging(1, 10*time.Second)
+			clock.Advance(10 * time.Second)
+			expected := heap.GetPriority(1)
+			clock.Advance(time.Second)
+			swapped, err := heap.CompareAndSetPriority(1, expected, 50)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swapped).Should(BeTrue())
+			Expect(heap.GetPriority(1)).Should(BeNumerically("~", 50, 1e-9))
+		})
+
+		It("Given a fibHeap with step aging, when time passes, it should improve priorities at every interval.", func() {
+			heap.SetAging(1, 10*time.Second)
+			heap.Insert(1, 100)
+
+			clock.Advance(9 * time.Second)
+			Expect(heap.GetPriority(1)).Should(BeNumerically("~", 100, 1e-9))
+			clock.Advance(time.Second)
+			Expect(heap.GetPriority(1)).Should(BeNumerically("~", 90, 1e-9))
+
+			heap.SetAging(0, 0)
+			clock.Advance(time.Hour)
+			Expect(heap.GetPriority(1)).Should(BeNumerically("~", 90, 1e-9))
+		})
+
+		It("Given a fibHeap with aging under a steady load of urgent values, when values are extracted every second, it should bound the wait of a value with a large priority.", func() {
+			heap.SetAging(1, 0)
+			heap.Insert(-1, 100)
+
+			waited := -1
+			for tick := 0; tick < 1000; tick++ {
+				heap.Insert(tick, 0)
+				clock.Advance(time.Second)
+				if data, _ := heap.ExtractMin(); data == -1 {
+					waited = tick
+					break
+				}
+			}
+
+			Expect(waited).Should(BeNumerically(">", 0))
+			Expect(waited).Should(BeNumerically("<=", 101))
+		})
+
+		It("Given a fibHeap without aging under a steady load of urgent values, when values are extracted every second, it should starve a value with a large priority.", func() {
+			heap.Insert(-1, 100)
+			for tick := 0; tick < 1000; tick++ {
+				heap.Insert(tick, 0)
+				clock.Advance(time.Second)
+				data, _ := heap.ExtractMin()
+				Expect(data).ShouldNot(BeEquivalentTo(-1))
+			}
+		})
+	})
+
+	Context("offset tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+			for i := 1; i <= 3; i++ {
+				heap.Insert(i, float64(i))
+			}
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with values, when call AddToAll api, it should shift every priority that is read.", func() {
+			Expect(heap.AddToAll(10)).ShouldNot(HaveOccurred())
+
+			_, priority := heap.Minimum()
+			Expect(priority).Should(BeEquivalentTo(11))
+			Expect(heap.GetPriority(3)).Should(BeEquivalentTo(13))
+			Expect(heap.Stats()).Should(ContainSubstring("Current min: priority(11.000000), data(1)"))
+			Expect(heap.Stats()).Should(ContainSubstring("< 11.000000 12.000000 13.000000 >"))
+
+			data, priority := heap.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(1))
+			Expect(priority).Should(BeEquivalentTo(11))
+		})
+
+		It("Given a fibHeap with an offset, when values are inserted and reprioritized, it should compare them with the shifted priorities.", func() {
+			heap.AddToAll(-10)
+			heap.Insert(4, -8.5)
+			Expect(heap.DecreasePriority(3, -9.5)).ShouldNot(HaveOccurred())
+			Expect(heap.IncreasePriority(1, -7.5)).ShouldNot(HaveOccurred())
+			Expect(heap.DecreasePriority(2, -7)).Should(HaveOccurred())
+
+			expected := []int{3, 4, 2, 1}
+			for _, e := range expected {
+				data, _ := heap.ExtractMin()
+				Expect(data).Should(BeEquivalentTo(e))
+			}
+		})
+
+		It("Given a fibHeap with hooks, when call AddToAll api, it should report the shifted minimum.", func() {
+			var priorities []float64
+			heap.SetHooks(fibheap.Hooks[int]{OnMinChange: func(data int, priority float64) {
+				Expect(data).Should(BeEquivalentTo(1))
+				priorities = append(priorities, priority)
+			}})
+
+			heap.AddToAll(10)
+			heap.AddToAll(0)
+			Expect(priorities).Should(Equal([]float64{11}))
+
+			empty := fibheap.NewFibHeap[int]()
+			empty.SetHooks(fibheap.Hooks[int]{OnMinChange: func(int, float64) { Fail("unexpected event") }})
+			empty.AddToAll(10)
+		})
+
+		It("Given a fibHeap, when call AddToAll api with a non-finite delta, it should return error.", func() {
+			Expect(heap.AddToAll(math.Inf(1))).Should(HaveOccurred())
+			Expect(heap.AddToAll(math.NaN())).Should(HaveOccurred())
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(1))
+		})
+	})
+
+	Context("context-bound tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap with a context-bound value, when the context is cancelled, it should delete the value.", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			Expect(heap.InsertCtx(ctx, 1, 1)).ShouldNot(HaveOccurred())
+			heap.Insert(2, 2)
+
+			cancel()
+			Eventually(heap.Num).Should(BeEquivalentTo(1))
+			data, _ := heap.Minimum()
+			Expect(data).Should(BeEquivalentTo(2))
+			Expect(heap.InsertCtx(ctx, 3, 3)).Should(MatchError(context.Canceled))
+		})
+
+		It("Given a fibHeap with a context-bound value, when the value is extracted and inserted again before the context is cancelled, it should keep the new value.", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			heap.InsertCtx(ctx, 1, 1)
+			heap.ExtractMin()
+			heap.Insert(1, 1)
+
+			cancel()
+			Consistently(heap.Num, 50*time.Millisecond).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a fibHeap with a context-bound value, when fn of Update api extracts it and returns an error, it should keep the value bound.", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			heap.InsertCtx(ctx, 1, 1)
+			heap.Update(func(tx *fibheap.Tx[int]) error {
+				tx.ExtractMin()
+				return fmt.Errorf("rollback")
+			})
+
+			cancel()
+			Eventually(heap.Num).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a fibHeap with many context-bound values, when cancellations race with ExtractMin, it should remove every value exactly once.", func() {
+			var deletedMutex sync.Mutex
+			deleted := map[int]bool{}
+			heap.SetHooks(fibheap.Hooks[int]{
+				OnDelete: func(data int, priority float64) {
+					deletedMutex.Lock()
+					defer deletedMutex.Unlock()
+					deleted[data] = true
+				},
+			})
+
+			cancels := make([]context.CancelFunc, 1000)
+			for i := range cancels {
+				var ctx context.Context
+				ctx, cancels[i] = context.WithCancel(context.Background())
+				Expect(heap.InsertCtx(ctx, i, rand.Float64())).ShouldNot(HaveOccurred())
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for _, i := range rand.Perm(len(cancels)) {
+					cancels[i]()
+				}
+			}()
+
+			extracted := map[int]bool{}
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					if data, priority := heap.ExtractMin(); !math.IsInf(priority, -1) {
+						extracted[data] = true
+					}
+				}
+			}()
+			wg.Wait()
+
+			Eventually(func() int {
+				deletedMutex.Lock()
+				defer deletedMutex.Unlock()
+				return len(extracted) + len(deleted)
+			}).Should(Equal(1000))
+			Expect(heap.Num()).Should(BeEquivalentTo(0))
+			deletedMutex.Lock()
+			defer deletedMutex.Unlock()
+			for data := range extracted {
+				Expect(deleted).ShouldNot(HaveKey(data))
+			}
+		})
+	})
+
+	Context("binary serialization tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+			for i := 0; i < 50; i++ {
+				heap.Insert(i, float64(i))
+			}
+			heap.ExtractMin()
+			heap.DecreasePriority(45, -1)
+			heap.DecreasePriority(46, -2)
+			heap.DecreasePriority(30, -3)
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap, when call MarshalBinary and UnmarshalBinary api, it should restore the same trees and marks.", func() {
+			payload, err := heap.MarshalBinary()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(restored.UnmarshalBinary(payload)).ShouldNot(HaveOccurred())
+			Expect(restored.Stats()).Should(Equal(heap.Stats()))
+
+			for _, h := range []*fibheap.FibHeap[int]{heap, restored} {
+				h.DecreasePriority(47, -4)
+				h.DecreasePriority(31, -5)
+				h.DecreasePriority(42, -6)
+			}
+			Expect(restored.Stats()).Should(Equal(heap.Stats()))
+
+			for heap.Num() > 0 {
+				data, priority := heap.ExtractMin()
+				restoredData, restoredPriority := restored.ExtractMin()
+				Expect(restoredData).Should(Equal(data))
+				Expect(restoredPriority).Should(Equal(priority))
+			}
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a fibHeap with an offset and a custom codec, when it is serialized, it should store the shifted priorities with the codec.", func() {
+			heap.AddToAll(100)
+			heap.SetCodec(decimalCodec{})
+			payload, err := heap.MarshalBinary()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(payload)).Should(ContainSubstring("45"))
+
+			restored := fibheap.NewFibHeap[int]()
+			restored.SetCodec(decimalCodec{})
+			Expect(restored.UnmarshalBinary(payload)).ShouldNot(HaveOccurred())
+			Expect(restored.GetPriority(30)).Should(BeEquivalentTo(97))
+			Expect(restored.Stats()).Should(Equal(heap.Stats()))
+		})
+
+		It("Given a serialized fibHeap, when it is unmarshaled into a non-empty heap or truncated, it should return error and leave the heap untouched.", func() {
+			payload, _ := heap.MarshalBinary()
+
+			restored := fibheap.NewFibHeap[int]()
+			restored.Insert(100, 100)
+			Expect(restored.UnmarshalBinary(payload)).Should(HaveOccurred())
+
+			restored = fibheap.NewFibHeap[int]()
+			Expect(restored.UnmarshalBinary(payload[:len(payload)/2])).Should(MatchError(fibheap.ErrCorrupt))
+			Expect(restored.UnmarshalBinary(append(payload, 0))).Should(MatchError(fibheap.ErrCorrupt))
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+
+			restored.SetCapacity(10, fibheap.Reject)
+			Expect(restored.UnmarshalBinary(payload)).Should(MatchError(fibheap.ErrFull))
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a payload that declares more values than it can hold, when it is unmarshaled, it should return ErrCorrupt without allocating for them.", func() {
+			payload := binary.AppendUvarint([]byte{1}, 1<<40)
+			payload = binary.AppendUvarint(payload, 1<<40)
+			Expect(payload).Should(HaveLen(13))
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(restored.UnmarshalBinary(payload)).Should(MatchError(fibheap.ErrCorrupt))
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+		})
+	})
+
+	Context("json serialization tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap, when call json.Marshal, it should return the values in priority order.", func() {
+			heap.Insert(3, math.Inf(1))
+			heap.Insert(1, 2.5)
+			heap.Insert(2, -1)
+
+			payload, err := json.Marshal(heap)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(payload)).Should(Equal(`[{"data":2,"priority":-1},{"data":1,"priority":2.5},{"data":3,"priority":"+Inf"}]`))
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(json.Unmarshal(payload, restored)).ShouldNot(HaveOccurred())
+			Expect(restored.Num()).Should(BeEquivalentTo(3))
+			Expect(restored.GetPriority(3)).Should(BeEquivalentTo(math.Inf(1)))
+			data, priority := restored.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(2))
+			Expect(priority).Should(BeEquivalentTo(-1))
+		})
+
+		It("Given a fibHeap with trees, when call MarshalJSONTree api, it should restore the same trees.", func() {
+			for i := 0; i < 20; i++ {
+				heap.Insert(i, float64(i))
+			}
+			heap.ExtractMin()
+			heap.DecreasePriority(15, -1)
+
+			payload, err := heap.MarshalJSONTree()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(payload)).Should(ContainSubstring(`"children":[`))
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(json.Unmarshal(payload, restored)).ShouldNot(HaveOccurred())
+			Expect(restored.Stats()).Should(Equal(heap.Stats()))
+		})
+
+		It("Given invalid json values, when call json.Unmarshal, it should return error and leave the heap empty.", func() {
+			inputs := []string{
+				`[{"data":1,"priority":"-Inf"}]`,
+				`[{"data":1,"priority":"NaN"}]`,
+				`[{"data":1,"priority":1},{"data":1,"priority":2}]`,
+				`[{"data":1,"priority":1,"children":[{"data":1,"priority":2}]}]`,
+				`[{"data":1,"priority":2,"children":[{"data":2,"priority":1}]}]`,
+				`[{"data":1,"priority":true}]`,
+			}
+			for _, input := range inputs {
+				Expect(json.Unmarshal([]byte(input), heap)).Should(HaveOccurred(), input)
+				Expect(heap.Num()).Should(BeEquivalentTo(0))
+			}
+			Expect(heap.Insert(1, math.NaN())).Should(HaveOccurred())
+		})
+	})
+
+	Context("snapshot tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+			for i := 0; i < 20; i++ {
+				heap.Insert(i, float64(i))
+			}
+			heap.ExtractMin()
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap, when call SaveSnapshot and LoadSnapshot api, it should restore the same heap.", func() {
+			var buffer bytes.Buffer
+			Expect(heap.SaveSnapshot(&buffer)).ShouldNot(HaveOccurred())
+			buffer.WriteString("trailing")
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(restored.LoadSnapshot(&buffer)).ShouldNot(HaveOccurred())
+			Expect(restored.Stats()).Should(Equal(heap.Stats()))
+			Expect(buffer.String()).Should(Equal("trailing"))
+		})
+
+		It("Given a truncated snapshot, when call LoadSnapshot api, it should return a descriptive error and leave the heap empty.", func() {
+			var buffer bytes.Buffer
+			heap.SaveSnapshot(&buffer)
+			snapshot := buffer.Bytes()
+
+			restored := fibheap.NewFibHeap[int]()
+			for length := 0; length < len(snapshot); length++ {
+				err := restored.LoadSnapshot(bytes.NewReader(snapshot[:length]))
+				Expect(err).Should(MatchError(fibheap.ErrCorrupt))
+				Expect(err.Error()).Should(ContainSubstring("truncated"))
+			}
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+		})
+
+		It("Given a damaged snapshot, when call LoadSnapshot api, it should return a descriptive error and leave the heap empty.", func() {
+			var buffer bytes.Buffer
+			heap.SaveSnapshot(&buffer)
+			snapshot := buffer.Bytes()
+
+			damage := func(offset int) []byte {
+				damaged := append([]byte(nil), snapshot...)
+				damaged[offset] ^= 0xff
+				return damaged
+			}
+
+			restored := fibheap.NewFibHeap[int]()
+			Expect(restored.LoadSnapshot(bytes.NewReader(damage(0)))).Should(MatchError(ContainSubstring("not a snapshot")))
+			Expect(restored.LoadSnapshot(bytes.NewReader(damage(4)))).Should(MatchError(ContainSubstring("version")))
+			Expect(restored.LoadSnapshot(bytes.NewReader(damage(len(snapshot) / 2)))).Should(MatchError(ContainSubstring("checksum does not match")))
+			Expect(restored.LoadSnapshot(bytes.NewReader(damage(len(snapshot) - 1)))).Should(MatchError(fibheap.ErrCorrupt))
+			Expect(restored.Num()).Should(BeEquivalentTo(0))
+		})
+	})
+
+	Context("node pool tests", func() {
+		BeforeEach(func() {
+			heap = fibheap.NewFibHeap[int]()
+			heap.SetNodePool(16)
+		})
+
+		AfterEach(func() {
+			heap = nil
+		})
+
+		It("Given a fibHeap that recycles nodes, when values churn, it should behave like a fibHeap that does not.", func() {
+			plain := fibheap.NewFibHeap[int]()
+			for i := 0; i < 5000; i++ {
+				data := rand.Intn(500)
+				priority := float64(rand.Intn(1000))
+				switch rand.Intn(5) {
+				case 0, 1:
+					Expect(heap.Insert(data, priority) == nil).Should(Equal(plain.Insert(data, priority) == nil))
+				case 2:
+					data, priority := heap.ExtractMin()
+					plainData, plainPriority := plain.ExtractMin()
+					Expect(data).Should(Equal(plainData))
+					Expect(priority).Should(Equal(plainPriority))
+				case 3:
+					Expect(heap.Delete(data) == nil).Should(Equal(plain.Delete(data) == nil))
+				case 4:
+					Expect(heap.DecreasePriority(data, priority-1000) == nil).Should(Equal(plain.DecreasePriority(data, priority-1000) == nil))
+				}
+			}
+			Expect(heap.Stats()).Should(Equal(plain.Stats()))
+		})
+
+		It("Given a fibHeap that recycles nodes, when a leased value expires after its node was reused, it should put back the leased value.", func() {
+			clock := newFakeClock()
+			heap.SetClock(clock)
+			heap.Insert(1, 1)
+			data, _ := heap.Lease(time.Second)
+			Expect(data).Should(BeEquivalentTo(1))
+			heap.Insert(2, 2)
+
+			clock.Advance(time.Second)
+			Eventually(heap.Num).Should(BeEquivalentTo(2))
+			Expect(heap.GetPriority(1)).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a fibHeap, when the node pool is set to a negative size, it should disable recycling.", func() {
+			Expect(func() { fibheap.NewFibHeap[int]().SetNodePool(-1) }).ShouldNot(Panic())
+
+			heap.Insert(1, 1)
+			heap.SetNodePool(-1)
+			heap.ExtractMin()
+			Expect(heap.Insert(2, 2)).Should(Succeed())
+			Expect(heap.Num()).Should(BeEquivalentTo(1))
+		})
+
+		It("Given a fibHeap that does not recycle nodes, when a value is removed while another one stays, it should release the removed data.", func() {
+			type payload [1 << 20]byte
+			large := fibheap.NewFibHeap[*payload]()
+			freed := make(chan struct{})
+
+			removed := new(payload)
+			runtime.SetFinalizer(removed, func(*payload) { close(freed) })
+			large.Insert(removed, 1)
+			large.Insert(new(payload), 2)
+			large.ExtractMin()
+			removed = nil
+
+			Eventually(func() bool {
+				runtime.GC()
+				select {
+				case <-freed:
+					return true
+				default:
+					return false
+				}
+			}).Should(BeTrue())
+			Expect(large.Num()).Should(BeEquivalentTo(1))
+		})
+	})
+})
+
+// decimalCodec encodes ints as decimal strings.
+type decimalCodec struct{}
+
+func (decimalCodec) Encode(data int) ([]byte, error) {
+	return []byte(strconv.Itoa(data)), nil
+}
+
+func (decimalCodec) Decode(payload []byte) (int, error) {
+	return strconv.Atoi(string(payload))
+}
+
+// An Item is something we manage in a priority queue.
+type Item struct {
+	value    string // The value of the item; arbitrary.
+	priority int    // The priority of the item in the queue.
+	// The index is needed by update and is maintained by the heap.Interface methods.
+	index int // The index of the item in the heap.
+}
+
+// A PriorityQueue implements heap.Interface and holds Items.
+type PriorityQueue []*Item
+
+func (pq PriorityQueue) Len() int { return len(pq) }
+
+func (pq PriorityQueue) Less(i, j int) bool {
+	// We want Pop to give us the highest, not lowest, priority so we use greater than here.
+	return pq[i].priority > pq[j].priority
+}
+
+func (pq PriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *PriorityQueue) Push(x any) {
+	n := len(*pq)
+	item := x.(*Item)
+	item.index = n
+	*pq = append(*pq, item)
+}
+
+func (pq *PriorityQueue) Pop() any {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil  // avoid memory leak
+	item.index = -1 // for safety
+	*pq = old[0 : n-1]
+	return item
+}
+
+// update modifies the priority and value of an Item in the queue.
+func (pq *PriorityQueue) update(item *Item, value string, priority int) {
+	item.value = value
+	item.priority = priority
+	heap.Fix(pq, item.index)
+}
+
+// fakeClock is a fibheap.Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mutex     sync.Mutex
+	now       time.Time
+	timers    []*fakeTimer
+	scheduled int
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+	done  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) fibheap.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	c.scheduled++
+	return timer
+}
+
+// Scheduled returns the number of timers created so far.
+func (c *fakeClock) Scheduled() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.scheduled
+}
+
+// Pending returns the number of timers that have neither fired nor been stopped.
+func (c *fakeClock) Pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pending := 0
+	for _, timer := range c.timers {
+		if !timer.done {
+			pending++
+		}
+	}
+	return pending
+}
+
+// Advance moves the clock forward and runs the callbacks of the timers that became due, in order.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if !timer.done && !timer.at.After(c.now) {
+			timer.done = true
+			due = append(due, timer)
+		} else if !timer.done {
+			timers = append(timers, timer)
+		}
+	}
+	c.timers = timers
+	c.mutex.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, timer := range due {
+		timer.f()
+	}
+}
+
+func (timer *fakeTimer) Stop() bool {
+	timer.clock.mutex.Lock()
+	defer timer.clock.mutex.Unlock()
+	stopped := !timer.done
+	timer.done = true
+	return stopped
 }
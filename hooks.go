@@ -0,0 +1,123 @@
+package fibheap
+
+import "math"
+
+// Hooks holds callbacks that observe changes to a heap. Any of them may be nil.
+// They run after the operation that triggered them has released the heap lock, so they may
+// call back into the heap, but hooks of concurrent operations can run in any order.
+// A panicking hook does not affect the heap: the remaining hooks still run and the panic
+// is then re-raised to the caller of the operation. Operations that run in the background,
+// such as the expiry of a lease or the deletion of a value bound to a context, have no caller,
+// so a panic in the hooks they fire is recovered and dropped.
+type Hooks[t any] struct {
+	// OnInsert is called for every inserted value.
+	OnInsert func(data t, priority float64)
+	// OnExtract is called for every value removed by ExtractMin.
+	OnExtract func(data t, priority float64)
+	// OnPriorityChange is called when the priority of a value is decreased or increased.
+	OnPriorityChange func(data t, old, priority float64)
+	// OnDelete is called for every value removed by key or evicted.
+	OnDelete func(data t, priority float64)
+	// OnMinChange is called when the minimum changes, with -inf once the heap is empty.
+	OnMinChange func(data t, priority float64)
+	// OnExpire is called for every value removed because its TTL elapsed.
+	OnExpire func(data t, priority float64)
+}
+
+// SetHooks registers the callbacks fired by later operations, replacing any earlier ones.
+func (heap *FibHeap[t]) SetHooks(hooks Hooks[t]) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	heap.hooks = &hooks
+	heap.lastMinData, heap.lastMinPriority = heap.minimum()
+}
+
+type eventKind int
+
+const (
+	eventInsert eventKind = iota
+	eventExtract
+	eventPriorityChange
+	eventDelete
+	eventMinChange
+	eventExpire
+)
+
+type event[t any] struct {
+	kind     eventKind
+	data     t
+	old      float64
+	priority float64
+}
+
+func (heap *FibHeap[t]) emit(kind eventKind, data t, old, priority float64) {
+	if heap.hooks != nil {
+		heap.events = append(heap.events, event[t]{kind: kind, data: data, old: heap.fromKey(old), priority: heap.fromKey(priority)})
+	}
+}
+
+func (heap *FibHeap[t]) minimum() (data t, f float64) {
+	if heap.min == nil {
+		return data, math.Inf(-1)
+	}
+	return heap.min.data, heap.min.priority
+}
+
+// unlock releases the heap lock and then fires the hooks for the events queued while it was held,
+// re-raising the first panic of a hook.
+func (heap *FibHeap[t]) unlock() {
+	if recovered := heap.release(); recovered != nil {
+		panic(recovered)
+	}
+}
+
+// release releases the heap lock and then fires the hooks for the events queued while it was held.
+// Returns the first panic of a hook. Background operations call it directly, as there is no one to re-raise it to.
+func (heap *FibHeap[t]) release() (recovered any) {
+	if heap.hooks == nil {
+		heap.mutex.Unlock()
+		return nil
+	}
+
+	if data, priority := heap.minimum(); priority != heap.lastMinPriority || any(data) != any(heap.lastMinData) {
+		heap.lastMinData, heap.lastMinPriority = data, priority
+		heap.emit(eventMinChange, data, 0, priority)
+	}
+
+	hooks := *heap.hooks
+	events := heap.events
+	heap.events = nil
+	heap.mutex.Unlock()
+
+	for _, e := range events {
+		if r := hooks.fire(e); r != nil && recovered == nil {
+			recovered = r
+		}
+	}
+
+	return recovered
+}
+
+func (hooks Hooks[t]) fire(e event[t]) (recovered any) {
+	defer func() {
+		recovered = recover()
+	}()
+
+	switch {
+	case e.kind == eventInsert && hooks.OnInsert != nil:
+		hooks.OnInsert(e.data, e.priority)
+	case e.kind == eventExtract && hooks.OnExtract != nil:
+		hooks.OnExtract(e.data, e.priority)
+	case e.kind == eventPriorityChange && hooks.OnPriorityChange != nil:
+		hooks.OnPriorityChange(e.data, e.old, e.priority)
+	case e.kind == eventDelete && hooks.OnDelete != nil:
+		hooks.OnDelete(e.data, e.priority)
+	case e.kind == eventMinChange && hooks.OnMinChange != nil:
+		hooks.OnMinChange(e.data, e.priority)
+	case e.kind == eventExpire && hooks.OnExpire != nil:
+		hooks.OnExpire(e.data, e.priority)
+	}
+
+	return nil
+}
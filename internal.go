@@ -2,50 +2,143 @@ package fibheap
 
 import (
 	"bytes"
-	"container/list"
+	binheap "container/heap"
 	"errors"
 	"fmt"
 	"math"
 )
 
-func probeTree[t any](buffer *bytes.Buffer, tree *list.List) {
+func (heap *FibHeap[t]) probeTree(buffer *bytes.Buffer, first *node[t]) {
 	buffer.WriteString(fmt.Sprintf("< "))
-	for e := tree.Front(); e != nil; e = e.Next() {
-		buffer.WriteString(fmt.Sprintf("%f ", e.Value.(*node[t]).priority))
-		if e.Value.(*node[t]).children.Len() != 0 {
-			probeTree[t](buffer, e.Value.(*node[t]).children)
+	for n := first; n != nil; n = n.next(first) {
+		buffer.WriteString(fmt.Sprintf("%f ", heap.fromKey(n.priority)))
+		if n.child != nil {
+			heap.probeTree(buffer, n.child)
 		}
 	}
 	buffer.WriteString(fmt.Sprintf("> "))
 }
 
+// next returns the sibling after n in the list that starts at first, or nil at the end of the list.
+func (n *node[t]) next(first *node[t]) *node[t] {
+	if n.right == first {
+		return nil
+	}
+	return n.right
+}
+
+// pushBack appends n to the end of the list that starts at *first.
+func pushBack[t any](first **node[t], n *node[t]) {
+	if *first == nil {
+		n.left, n.right = n, n
+		*first = n
+		return
+	}
+
+	last := (*first).left
+	n.left, n.right = last, *first
+	last.right = n
+	(*first).left = n
+}
+
+// splice appends the whole list that starts at other to the end of the list that starts at *first.
+func splice[t any](first **node[t], other *node[t]) {
+	if *first == nil {
+		*first = other
+		return
+	}
+
+	last, otherLast := (*first).left, other.left
+	last.right, other.left = other, last
+	otherLast.right, (*first).left = *first, otherLast
+}
+
+// unlink removes n from the list that starts at *first.
+func unlink[t any](first **node[t], n *node[t]) {
+	if n.right == n {
+		*first = nil
+	} else {
+		n.left.right, n.right.left = n.right, n.left
+		if *first == n {
+			*first = n.right
+		}
+	}
+	n.left, n.right = nil, nil
+}
+
+// length returns the number of nodes in the list that starts at first.
+func length[t any](first *node[t]) int {
+	count := 0
+	for n := first; n != nil; n = n.next(first) {
+		count++
+	}
+	return count
+}
+
+// items returns a snapshot of the values in the heap, taken under its own lock.
+func (heap *FibHeap[t]) items() []item[t] {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	items := make([]item[t], 0, len(heap.index))
+	for _, node := range heap.index {
+		items = append(items, item[t]{data: node.data, priority: heap.fromKey(node.priority)})
+	}
+	return items
+}
+
 func (heap *FibHeap[t]) deleteNode(n *node[t]) {
+	heap.removeNode(n, eventDelete)
+}
+
+// removeNode deletes n from the heap as a single operation, reported to the hooks as the given event.
+func (heap *FibHeap[t]) removeNode(n *node[t], kind eventKind) {
+	data, priority, deadline, b := n.data, n.priority, heap.deadline(n.data), heap.bound[n.data]
+
+	journal, events := heap.journal, len(heap.events)
+	heap.journal = nil
 	heap.decreaseKey(n, math.Inf(-1))
 	heap.extractMin()
+	heap.journal = journal
+	if heap.hooks != nil {
+		heap.events = heap.events[:events]
+	}
+
+	if heap.journal != nil {
+		heap.record(func() { heap.reinsert(data, priority, deadline, b) })
+	}
+	heap.emit(kind, data, priority, priority)
+}
+
+// record remembers how to undo a mutation while an Update is running.
+// Undo functions look values up by data, since the nodes themselves may have been replaced.
+// Callers check heap.journal first, so that no undo function is allocated outside an Update.
+func (heap *FibHeap[t]) record(undo func()) {
+	heap.journal = append(heap.journal, undo)
 }
 
 func (heap *FibHeap[t]) link(parent, child *node[t]) {
 	child.marked = false
 	child.parent = parent
-	child.self = parent.children.PushBack(child)
+	pushBack(&parent.child, child)
 	parent.degree++
 }
 
 func (heap *FibHeap[t]) resetMin() {
-	heap.min = heap.roots.Front().Value.(*node[t])
-	for tree := heap.min.self.Next(); tree != nil; tree = tree.Next() {
-		if tree.Value.(*node[t]).priority < heap.min.priority {
-			heap.min = tree.Value.(*node[t])
+	heap.min = heap.roots
+	for tree := heap.roots.next(heap.roots); tree != nil; tree = tree.next(heap.roots) {
+		if tree.priority < heap.min.priority {
+			heap.min = tree
 		}
 	}
 }
 
 func (heap *FibHeap[t]) cut(n *node[t]) {
-	n.parent.children.Remove(n.self)
+	unlink(&n.parent.child, n)
 	n.parent.degree--
 	n.parent = nil
 	n.marked = false
-	n.self = heap.roots.PushBack(n)
+	pushBack(&heap.roots, n)
 }
 
 func (heap *FibHeap[t]) cascadingCut(n *node[t]) {
@@ -60,108 +153,148 @@ func (heap *FibHeap[t]) cascadingCut(n *node[t]) {
 	}
 }
 
+// consolidate links the roots of equal degree until every root has a distinct degree, using treeDegrees
+// as the table of roots by degree. The table grows as needed and is left empty for the next consolidation.
 func (heap *FibHeap[t]) consolidate() {
-	for tree := heap.roots.Front(); tree != nil; tree = tree.Next() {
-		heap.treeDegrees[tree.Value.(*node[t]).position] = nil
-	}
-
-	for tree := heap.roots.Front(); tree != nil; {
-		if heap.treeDegrees[tree.Value.(*node[t]).degree] == nil {
-			heap.treeDegrees[tree.Value.(*node[t]).degree] = tree
-			tree.Value.(*node[t]).position = tree.Value.(*node[t]).degree
-			tree = tree.Next()
-			continue
+	for tree := heap.roots; tree != nil; {
+		for int(tree.degree) >= len(heap.treeDegrees) {
+			heap.treeDegrees = append(heap.treeDegrees, nil)
 		}
 
-		if heap.treeDegrees[tree.Value.(*node[t]).degree] == tree {
-			tree = tree.Next()
+		anotherTree := heap.treeDegrees[tree.degree]
+		if anotherTree == nil || anotherTree == tree {
+			heap.treeDegrees[tree.degree] = tree
+			tree = tree.next(heap.roots)
 			continue
 		}
 
-		for heap.treeDegrees[tree.Value.(*node[t]).degree] != nil {
-			anotherTree := heap.treeDegrees[tree.Value.(*node[t]).degree]
-			heap.treeDegrees[tree.Value.(*node[t]).degree] = nil
-			if tree.Value.(*node[t]).priority <= anotherTree.Value.(*node[t]).priority {
-				heap.roots.Remove(anotherTree)
-				heap.link(tree.Value.(*node[t]), anotherTree.Value.(*node[t]))
-			} else {
-				heap.roots.Remove(tree)
-				heap.link(anotherTree.Value.(*node[t]), tree.Value.(*node[t]))
-				tree = anotherTree
-			}
+		heap.treeDegrees[tree.degree] = nil
+		if tree.priority <= anotherTree.priority {
+			unlink(&heap.roots, anotherTree)
+			heap.link(tree, anotherTree)
+		} else {
+			unlink(&heap.roots, tree)
+			heap.link(anotherTree, tree)
+			tree = anotherTree
 		}
-		heap.treeDegrees[tree.Value.(*node[t]).degree] = tree
-		tree.Value.(*node[t]).position = tree.Value.(*node[t]).degree
 	}
+	clear(heap.treeDegrees)
 
 	heap.resetMin()
 }
 
-func (heap *FibHeap[t]) insert(data t, priority float64) error {
+func (heap *FibHeap[t]) validate(data t, priority float64) error {
 	if math.IsInf(priority, -1) {
 		return errors.New("Negative infinity priority is reserved for internal usage ")
 	}
 
-	heap.mutex.Lock()
-	defer heap.mutex.Unlock()
+	if math.IsNaN(priority) {
+		return errors.New("NaN priority is not allowed ")
+	}
 
 	if _, exists := heap.index[data]; exists {
 		return errors.New("Duplicate data is not allowed ")
 	}
 
-	node := new(node[t])
-	node.children = list.New()
+	if _, leased := heap.leases[data]; leased {
+		return errors.New("Duplicate data is not allowed ")
+	}
+
+	return nil
+}
+
+func (heap *FibHeap[t]) insert(data t, priority float64) error {
+	if err := heap.validate(data, priority); err != nil {
+		return err
+	}
+
+	node := heap.newNode()
 	node.data = data
 	node.priority = priority
+	node.size = heap.sizeOf(data)
 
-	node.self = heap.roots.PushBack(node)
+	pushBack(&heap.roots, node)
 	heap.index[node.data] = node
 	heap.num++
+	heap.weight += node.size
+
+	if heap.maxHeap != nil {
+		binheap.Push(heap.maxHeap, node)
+	}
 
 	if heap.min == nil || heap.min.priority > node.priority {
 		heap.min = node
 	}
 
+	if heap.journal != nil {
+		heap.record(func() { heap.deleteNode(heap.index[data]) })
+	}
+	heap.emit(eventInsert, data, priority, priority)
 	return nil
 }
 
-func (heap *FibHeap[t]) extractMin() *node[t] {
-	heap.mutex.Lock()
-	defer heap.mutex.Unlock()
-
+// extractMin removes the minimum and returns its data and priority. Its node is recycled.
+func (heap *FibHeap[t]) extractMin() item[t] {
 	min := heap.min
 
-	children := heap.min.children
-	if children != nil {
-		for e := children.Front(); e != nil; e = e.Next() {
-			e.Value.(*node[t]).parent = nil
-			e.Value.(*node[t]).self = heap.roots.PushBack(e.Value.(*node[t]))
+	if min.child != nil {
+		for child := min.child; child != nil; child = child.next(min.child) {
+			child.parent = nil
 		}
+		splice(&heap.roots, min.child)
+		min.child = nil
 	}
 
-	heap.roots.Remove(heap.min.self)
-	heap.treeDegrees[min.position] = nil
+	unlink(&heap.roots, min)
 	delete(heap.index, heap.min.data)
 	heap.num--
 
+	deadline := heap.deadline(min.data)
+	if !math.IsInf(deadline, -1) {
+		heap.expiry.deleteNode(heap.expiry.index[min.data])
+	}
+	b := heap.unbind(min.data)
+	heap.weight -= min.size
+
+	if heap.maxHeap != nil {
+		binheap.Remove(heap.maxHeap, min.maxIndex)
+	}
+	if heap.capacity > 0 || heap.budget > 0 {
+		heap.space.Broadcast()
+	}
+
 	if heap.num == 0 {
 		heap.min = nil
 	} else {
 		heap.consolidate()
 	}
 
-	return min
+	removed := item[t]{data: min.data, priority: min.priority}
+	heap.recycle(min)
+
+	if heap.journal != nil {
+		heap.record(func() { heap.reinsert(removed.data, removed.priority, deadline, b) })
+	}
+	heap.emit(eventExtract, removed.data, removed.priority, removed.priority)
+	return removed
 }
 
 func (heap *FibHeap[t]) decreaseKey(n *node[t], priority float64) error {
-	heap.mutex.Lock()
-	defer heap.mutex.Unlock()
-
 	if priority >= n.priority {
 		return errors.New("New priority is not smaller than current priority ")
 	}
 
+	data, old := n.data, n.priority
+	if heap.journal != nil {
+		heap.record(func() { heap.increaseKey(heap.index[data], old) })
+	}
+	heap.emit(eventPriorityChange, data, old, priority)
+
 	n.priority = priority
+	if heap.maxHeap != nil {
+		binheap.Fix(heap.maxHeap, n.maxIndex)
+	}
+
 	if n.parent != nil {
 		parent := n.parent
 		if n.priority < n.parent.priority {
@@ -178,19 +311,25 @@ func (heap *FibHeap[t]) decreaseKey(n *node[t], priority float64) error {
 }
 
 func (heap *FibHeap[t]) increaseKey(n *node[t], priority float64) error {
-	heap.mutex.Lock()
-	defer heap.mutex.Unlock()
-
 	if priority <= n.priority {
 		return errors.New("New priority is not larger than current priority ")
 	}
 
+	data, old := n.data, n.priority
+	if heap.journal != nil {
+		heap.record(func() { heap.decreaseKey(heap.index[data], old) })
+	}
+	heap.emit(eventPriorityChange, data, old, priority)
+
 	n.priority = priority
+	if heap.maxHeap != nil {
+		binheap.Fix(heap.maxHeap, n.maxIndex)
+	}
 
-	child := n.children.Front()
-	for child != nil {
-		childNode := child.Value.(*node[t])
-		child = child.Next()
+	// The next child is found before cutting, against the first child left at that point.
+	for child := n.child; child != nil; {
+		childNode := child
+		child = child.next(n.child)
 		if childNode.priority < n.priority {
 			heap.cut(childNode)
 			heap.cascadingCut(n)
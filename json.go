@@ -0,0 +1,144 @@
+package fibheap
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// jsonValue is the JSON form of a value. Children and Marked are only written in tree mode.
+type jsonValue[t any] struct {
+	Data     t              `json:"data"`
+	Priority jsonPriority   `json:"priority"`
+	Marked   bool           `json:"marked,omitempty"`
+	Children []jsonValue[t] `json:"children,omitempty"`
+}
+
+// jsonPriority writes infinite and NaN priorities as the strings "+Inf", "-Inf" and "NaN",
+// which JSON numbers cannot represent.
+type jsonPriority float64
+
+func (priority jsonPriority) MarshalJSON() ([]byte, error) {
+	f := float64(priority)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Marshal(f)
+}
+
+func (priority *jsonPriority) UnmarshalJSON(payload []byte) error {
+	var f float64
+	if err := json.Unmarshal(payload, &f); err == nil {
+		*priority = jsonPriority(f)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return errors.New("Priority is neither a number nor a string")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*priority = jsonPriority(f)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// It writes the values as an array of {"data", "priority"} objects in priority order.
+// Infinite priorities are written as the strings "+Inf" and "-Inf".
+func (heap *FibHeap[t]) MarshalJSON() ([]byte, error) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
+	values := make([]jsonValue[t], 0, heap.num)
+	for _, node := range heap.index {
+		values = append(values, jsonValue[t]{Data: node.data, Priority: jsonPriority(heap.fromKey(node.priority))})
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Priority < values[j].Priority
+	})
+
+	return json.Marshal(values)
+}
+
+// MarshalJSONTree writes the values as nested objects that follow the trees of the heap,
+// with "marked" and "children" fields, so that UnmarshalJSON restores the same shape.
+func (heap *FibHeap[t]) MarshalJSONTree() ([]byte, error) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
+	return json.Marshal(heap.jsonTrees(heap.roots))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// It accepts the output of both MarshalJSON and MarshalJSONTree. The heap must have been created
+// by NewFibHeap and be empty. Values are validated as by Insert, and the restored values do not fire hooks.
+// Returns an error if the heap is not empty or the input holds invalid values,
+// or ErrFull if a bounded heap cannot take every value.
+func (heap *FibHeap[t]) UnmarshalJSON(payload []byte) error {
+	var values []jsonValue[t]
+	if err := json.Unmarshal(payload, &values); err != nil {
+		return err
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.num != 0 {
+		return errors.New("Heap is not empty")
+	}
+
+	var num uint
+	size := 0
+	seen := make(map[interface{}]bool)
+	roots := make([]*node[t], 0, len(values))
+	for _, value := range values {
+		root, err := heap.jsonNode(value, nil, seen, &num, &size)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+
+	return heap.restore(roots, num, size)
+}
+
+// jsonTrees converts the sibling list starting at first to its JSON form.
+func (heap *FibHeap[t]) jsonTrees(first *node[t]) []jsonValue[t] {
+	values := make([]jsonValue[t], 0)
+	for n := first; n != nil; n = n.next(first) {
+		values = append(values, jsonValue[t]{
+			Data:     n.data,
+			Priority: jsonPriority(heap.fromKey(n.priority)),
+			Marked:   n.marked,
+			Children: heap.jsonTrees(n.child),
+		})
+	}
+	return values
+}
+
+// jsonNode builds the detached tree for value under parent, counting its values and their size.
+func (heap *FibHeap[t]) jsonNode(value jsonValue[t], parent *node[t], seen map[interface{}]bool, num *uint, size *int) (*node[t], error) {
+	n, err := heap.restoredNode(value.Data, float64(value.Priority), value.Marked, parent, seen)
+	if err != nil {
+		return nil, err
+	}
+	*num++
+	*size += n.size
+
+	for _, child := range value.Children {
+		if _, err := heap.jsonNode(child, n, seen, num, size); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,126 @@
+package fibheap
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// lease keeps what the value needs to go back into the heap: its priority, and its deadline and context binding,
+// if any, which are restored along with it.
+type lease[t any] struct {
+	priority float64
+	deadline float64
+	bound    *binding
+	size     int
+	timer    Timer
+}
+
+// Lease hides the current minimum from the heap for ttl and returns its data and priority.
+// The value must then be acknowledged with Ack, or returned with Nack; if neither happens
+// before ttl elapses, it goes back into the heap with its original priority, keeping any credit from aging.
+// A value that goes back keeps the deadline of InsertWithTTL and the context of InsertCtx it was inserted with.
+// Leased values still count as present, so inserting the same data fails until the lease ends,
+// and they count toward the bounds set by SetCapacity and SetBudget.
+// Once the heap is closed, expired leases are dropped instead.
+// Returns nil/-inf if the heap is empty.
+func (heap *FibHeap[t]) Lease(ttl time.Duration) (data t, f float64) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
+	if heap.num == 0 {
+		return data, math.Inf(-1)
+	}
+
+	deadline, bound := heap.deadline(heap.min.data), heap.bound[heap.min.data]
+	min := heap.extractMin()
+
+	l := &lease[t]{priority: min.priority, deadline: deadline, bound: bound, size: heap.sizeOf(min.data)}
+	l.timer = heap.clock.AfterFunc(ttl, func() {
+		heap.expireLease(min.data, l)
+	})
+	heap.leases[min.data] = l
+	heap.leaseWeight += l.size
+
+	return min.data, heap.fromKey(min.priority)
+}
+
+// Ack ends the lease on the value with the given data and removes it for good, forgetting its retry attempts.
+// Returns an error if the value is not leased.
+func (heap *FibHeap[t]) Ack(data t) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	l, exists := heap.leases[data]
+	if !exists {
+		return errors.New("Lease is not found")
+	}
+
+	heap.endLease(data, l)
+	delete(heap.attempts, data)
+
+	return nil
+}
+
+// Nack ends the lease on the value with the given data and puts it back into the heap with the given priority.
+// Returns an error if the value is not leased or the priority is negative infinity or NaN,
+// or ErrClosed if the heap is closed, in which case the value stays leased.
+func (heap *FibHeap[t]) Nack(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+	if math.IsNaN(priority) {
+		return errors.New("NaN priority is not allowed ")
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	l, exists := heap.leases[data]
+	if !exists {
+		return errors.New("Lease is not found")
+	}
+
+	heap.endLease(data, l)
+	if err := heap.reinsert(data, heap.toKey(priority), l.deadline, l.bound); err != nil {
+		return err
+	}
+	heap.cond.Signal()
+
+	return nil
+}
+
+func (heap *FibHeap[t]) expireLease(data t, l *lease[t]) {
+	heap.mutex.Lock()
+	defer heap.release()
+
+	if heap.leases[data] != l {
+		return
+	}
+
+	heap.endLease(data, l)
+	if heap.closed {
+		delete(heap.attempts, data)
+		return
+	}
+
+	heap.reinsert(data, l.priority, l.deadline, l.bound)
+	heap.cond.Signal()
+}
+
+// endLease forgets the lease l on data, releasing its share of the bounds.
+func (heap *FibHeap[t]) endLease(data t, l *lease[t]) {
+	l.timer.Stop()
+	delete(heap.leases, data)
+	heap.leaseWeight -= l.size
+
+	if heap.capacity > 0 || heap.budget > 0 {
+		heap.space.Broadcast()
+	}
+}
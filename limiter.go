@@ -0,0 +1,255 @@
+package fibheap
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate is a token-bucket rate: tokens refill at PerSecond up to Burst, and each released value takes one.
+// A PerSecond of zero or less means no limit.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// Limiter releases values in priority order, no faster than a token-bucket rate.
+// Values can be split into classes, each with its own heap and rate; Next releases the smallest
+// priority among the classes that have a token available.
+type Limiter[t any] struct {
+	mutex    sync.Mutex
+	clock    Clock
+	classify func(t) string
+	rate     Rate
+	classes  map[string]*class[t]
+	index    map[interface{}]*class[t]
+	changed  chan struct{}
+	closed   bool
+}
+
+type class[t any] struct {
+	heap   *FibHeap[t]
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates an empty limiter whose classes are limited to the given rate by default.
+// All values belong to a single class until SetClassifier is called.
+func NewLimiter[t any](rate Rate) *Limiter[t] {
+	limiter := new(Limiter[t])
+	limiter.clock = systemClock{}
+	limiter.classify = func(t) string { return "" }
+	limiter.rate = rate
+	limiter.classes = make(map[string]*class[t])
+	limiter.index = make(map[interface{}]*class[t])
+	limiter.changed = make(chan struct{})
+
+	return limiter
+}
+
+// SetClock replaces the clock used to refill tokens and wait for them.
+func (limiter *Limiter[t]) SetClock(clock Clock) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.clock = clock
+}
+
+// SetClassifier sets the function giving the class of each value. It must be called before any value is inserted.
+func (limiter *Limiter[t]) SetClassifier(classify func(t) string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.classify = classify
+}
+
+// SetClassRate sets the rate of one class, replacing the default rate given to NewLimiter.
+func (limiter *Limiter[t]) SetClassRate(name string, rate Rate) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	c := limiter.class(name)
+	c.rate = rate
+	c.tokens = math.Min(c.tokens, float64(c.burst()))
+	limiter.notify()
+}
+
+// Num returns the total number of values waiting in the limiter.
+func (limiter *Limiter[t]) Num() uint {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return uint(len(limiter.index))
+}
+
+// Insert inserts a new value with the given data and priority into the heap of its class.
+// Returns an error if the insertion fails.
+func (limiter *Limiter[t]) Insert(data t, priority float64) error {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.closed {
+		return ErrClosed
+	}
+
+	if _, exists := limiter.index[data]; exists {
+		return errors.New("Duplicate data is not allowed")
+	}
+
+	c := limiter.class(limiter.classify(data))
+	if err := c.heap.Insert(data, priority); err != nil {
+		return err
+	}
+
+	limiter.index[data] = c
+	limiter.notify()
+	return nil
+}
+
+// Delete removes the value with the given data from the limiter.
+// Returns an error if the data is not found.
+func (limiter *Limiter[t]) Delete(data t) error {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	c, exists := limiter.index[data]
+	if !exists {
+		return errors.New("Tag is not found")
+	}
+
+	delete(limiter.index, data)
+	return c.heap.Delete(data)
+}
+
+// Next blocks until a value is waiting and its class has a token, then releases the smallest
+// priority among the classes with a token and returns its data and priority.
+// Returns the context error if ctx is done first, or ErrClosed once the limiter is closed and empty.
+func (limiter *Limiter[t]) Next(ctx context.Context) (data t, f float64, err error) {
+	for {
+		limiter.mutex.Lock()
+		data, f, wait, ok := limiter.release()
+		if ok {
+			limiter.mutex.Unlock()
+			return data, f, nil
+		}
+		if limiter.closed && len(limiter.index) == 0 {
+			limiter.mutex.Unlock()
+			return data, math.Inf(-1), ErrClosed
+		}
+
+		changed := limiter.changed
+		var fired chan struct{}
+		var timer Timer
+		if wait > 0 {
+			fired = make(chan struct{})
+			timer = limiter.clock.AfterFunc(wait, func() { close(fired) })
+		}
+		limiter.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-changed:
+		case <-fired:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return data, math.Inf(-1), err
+		}
+	}
+}
+
+// Close closes the limiter, so that Insert fails and Next returns ErrClosed once the limiter is empty.
+// Returns ErrClosed if the limiter was already closed.
+func (limiter *Limiter[t]) Close() error {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.closed {
+		return ErrClosed
+	}
+
+	limiter.closed = true
+	limiter.notify()
+	return nil
+}
+
+// release takes a token from the class with a token and the smallest minimum, and extracts that minimum.
+// If no class can release, it returns how long until the next token for a non-empty class, or zero if all are empty.
+func (limiter *Limiter[t]) release() (data t, f float64, wait time.Duration, ok bool) {
+	now := limiter.clock.Now()
+
+	var best *class[t]
+	min := math.Inf(1)
+	for _, c := range limiter.classes {
+		if c.heap.Num() == 0 {
+			continue
+		}
+
+		c.refill(now)
+		if c.tokens < 1 {
+			if w := c.wait(); wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+
+		if _, priority := c.heap.Minimum(); best == nil || priority < min {
+			best, min = c, priority
+		}
+	}
+
+	if best == nil {
+		return data, f, wait, false
+	}
+
+	if best.rate.PerSecond > 0 {
+		best.tokens--
+	}
+	data, f = best.heap.ExtractMin()
+	delete(limiter.index, data)
+	return data, f, 0, true
+}
+
+func (limiter *Limiter[t]) class(name string) *class[t] {
+	c, exists := limiter.classes[name]
+	if !exists {
+		c = &class[t]{heap: NewFibHeap[t](), rate: limiter.rate, last: limiter.clock.Now()}
+		c.tokens = float64(c.burst())
+		limiter.classes[name] = c
+	}
+	return c
+}
+
+// notify wakes every goroutine in Next. The limiter lock must be held.
+func (limiter *Limiter[t]) notify() {
+	close(limiter.changed)
+	limiter.changed = make(chan struct{})
+}
+
+func (c *class[t]) burst() int {
+	if c.rate.Burst < 1 {
+		return 1
+	}
+	return c.rate.Burst
+}
+
+func (c *class[t]) refill(now time.Time) {
+	if c.rate.PerSecond <= 0 {
+		c.tokens = float64(c.burst())
+	} else {
+		c.tokens = math.Min(float64(c.burst()), c.tokens+now.Sub(c.last).Seconds()*c.rate.PerSecond)
+	}
+	c.last = now
+}
+
+// wait returns how long until the class has a whole token.
+func (c *class[t]) wait() time.Duration {
+	return time.Duration(math.Ceil((1 - c.tokens) / c.rate.PerSecond * float64(time.Second)))
+}
@@ -0,0 +1,102 @@
+package fibheap_test
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tests of limiter", func() {
+	var (
+		limiter *fibheap.Limiter[string]
+		clock   *fakeClock
+	)
+
+	next := func() <-chan string {
+		done := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			data, _, err := limiter.Next(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			done <- data
+		}()
+		return done
+	}
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		limiter = fibheap.NewLimiter[string](fibheap.Rate{PerSecond: 1, Burst: 2})
+		limiter.SetClock(clock)
+	})
+
+	AfterEach(func() {
+		limiter = nil
+	})
+
+	It("Given a limiter with values, when call Next api, it should release them in priority order no faster than the rate.", func() {
+		limiter.Insert("c", 3)
+		limiter.Insert("a", 1)
+		limiter.Insert("b", 2)
+		Expect(limiter.Insert("a", 0)).Should(HaveOccurred())
+
+		Eventually(next()).Should(Receive(Equal("a")))
+		Eventually(next()).Should(Receive(Equal("b")))
+
+		done := next()
+		Eventually(clock.Pending).Should(Equal(1))
+		clock.Advance(999 * time.Millisecond)
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		clock.Advance(time.Millisecond)
+		Eventually(done).Should(Receive(Equal("c")))
+		Expect(limiter.Num()).Should(BeEquivalentTo(0))
+	})
+
+	It("Given a limiter with classes, when a class runs out of tokens, it should release the best value of the other classes.", func() {
+		limiter.SetClassifier(func(data string) string { return data[:1] })
+		limiter.SetClassRate("s", fibheap.Rate{PerSecond: 1, Burst: 1})
+		limiter.SetClassRate("f", fibheap.Rate{})
+
+		limiter.Insert("s1", 1)
+		limiter.Insert("s2", 2)
+		limiter.Insert("f1", 10)
+		limiter.Insert("f2", 20)
+
+		Eventually(next()).Should(Receive(Equal("s1")))
+		Eventually(next()).Should(Receive(Equal("f1")))
+		Eventually(next()).Should(Receive(Equal("f2")))
+
+		done := next()
+		Eventually(clock.Pending).Should(Equal(1))
+		clock.Advance(time.Second)
+		Eventually(done).Should(Receive(Equal("s2")))
+	})
+
+	It("Given an empty limiter blocked in Next, when a value is inserted, it should release it.", func() {
+		done := next()
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+		limiter.Insert("a", 1)
+		Eventually(done).Should(Receive(Equal("a")))
+	})
+
+	It("Given a limiter, when the context of Next is cancelled or the limiter is closed, it should return an error.", func() {
+		limiter.Insert("a", 1)
+		Expect(limiter.Delete("a")).ShouldNot(HaveOccurred())
+		Expect(limiter.Delete("a")).Should(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, priority, err := limiter.Next(ctx)
+		Expect(err).Should(MatchError(context.Canceled))
+		Expect(priority).Should(BeEquivalentTo(math.Inf(-1)))
+
+		Expect(limiter.Close()).ShouldNot(HaveOccurred())
+		Expect(limiter.Insert("b", 1)).Should(MatchError(fibheap.ErrClosed))
+		_, _, err = limiter.Next(context.Background())
+		Expect(err).Should(MatchError(fibheap.ErrClosed))
+	})
+})
@@ -0,0 +1,271 @@
+package fibheap
+
+import (
+	"bytes"
+	binheap "container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// binaryVersion is the version of the format written by MarshalBinary.
+const binaryVersion = 1
+
+// minNodeSize is the smallest encoding of a node: its priority, its marked flag, and one byte each
+// for its degree and the length of its data.
+const minNodeSize = 11
+
+// ErrCorrupt is returned when serialized heap data is malformed.
+var ErrCorrupt = errors.New("Serialized heap is corrupt")
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// It captures the root list, the child lists, the marked flags, the degrees and the priorities,
+// so that a restored heap keeps its amortized shape. Data is encoded with the heap's Codec.
+// Leases, deadlines and context bindings are not captured.
+func (heap *FibHeap[t]) MarshalBinary() ([]byte, error) {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	heap.expire()
+
+	return heap.marshalBinary()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The heap must have been created by NewFibHeap and be empty. The restored values do not fire hooks.
+// Returns an error if the heap is not empty, the data is corrupt or holds invalid values,
+// or ErrFull if a bounded heap cannot take every value.
+func (heap *FibHeap[t]) UnmarshalBinary(payload []byte) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	return heap.unmarshalBinary(payload)
+}
+
+func (heap *FibHeap[t]) marshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(binaryVersion)
+	buffer.Write(binary.AppendUvarint(nil, uint64(heap.num)))
+	buffer.Write(binary.AppendUvarint(nil, uint64(length(heap.roots))))
+
+	codec := heap.payloadCodec()
+	for n := heap.roots; n != nil; n = n.next(heap.roots) {
+		if err := heap.marshalTree(&buffer, codec, n); err != nil {
+			return nil, err
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (heap *FibHeap[t]) unmarshalBinary(payload []byte) error {
+	if heap.num != 0 {
+		return errors.New("Heap is not empty")
+	}
+
+	reader := bytes.NewReader(payload)
+	if version, err := reader.ReadByte(); err != nil || version != binaryVersion {
+		return ErrCorrupt
+	}
+	num, err := binary.ReadUvarint(reader)
+	if err != nil || num > uint64(reader.Len()/minNodeSize) {
+		return ErrCorrupt
+	}
+	count, err := binary.ReadUvarint(reader)
+	if err != nil || count > num {
+		return ErrCorrupt
+	}
+
+	decoder := &treeDecoder[t]{heap: heap, reader: reader, codec: heap.payloadCodec(), left: num, seen: make(map[interface{}]bool)}
+	roots := make([]*node[t], 0, count)
+	for i := uint64(0); i < count; i++ {
+		root, err := decoder.tree(nil)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+	if decoder.left != 0 || reader.Len() != 0 {
+		return ErrCorrupt
+	}
+
+	return heap.restore(roots, uint(num), decoder.size)
+}
+
+// replaceBinary replaces the values of the heap with the ones in payload, without firing hooks for the values replaced.
+// The current values are set aside while payload is decoded, and put back if it is invalid.
+func (heap *FibHeap[t]) replaceBinary(payload []byte) error {
+	roots, index, min, num, weight, maxHeap := heap.roots, heap.index, heap.min, heap.num, heap.weight, heap.maxHeap
+
+	heap.roots, heap.index, heap.min, heap.num, heap.weight = nil, make(map[interface{}]*node[t]), nil, 0, 0
+	if maxHeap != nil {
+		heap.maxHeap = newMaxHeap(heap.index)
+	}
+
+	if err := heap.unmarshalBinary(payload); err != nil {
+		heap.roots, heap.index, heap.min, heap.num, heap.weight, heap.maxHeap = roots, index, min, num, weight, maxHeap
+		return err
+	}
+
+	// The deadlines and context bindings of the values that are gone go with them.
+	for _, n := range index {
+		if _, kept := heap.index[n.data]; kept {
+			continue
+		}
+		if !math.IsInf(heap.deadline(n.data), -1) {
+			heap.expiry.deleteNode(heap.expiry.index[n.data])
+		}
+		heap.unbind(n.data)
+	}
+
+	return nil
+}
+
+// marshalTree writes n and then its children, depth first.
+func (heap *FibHeap[t]) marshalTree(buffer *bytes.Buffer, codec Codec[t], n *node[t]) error {
+	payload, err := codec.Encode(n.data)
+	if err != nil {
+		return err
+	}
+
+	buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(heap.fromKey(n.priority))))
+	if n.marked {
+		buffer.WriteByte(1)
+	} else {
+		buffer.WriteByte(0)
+	}
+	buffer.Write(binary.AppendUvarint(nil, uint64(n.degree)))
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(payload))))
+	buffer.Write(payload)
+
+	for child := n.child; child != nil; child = child.next(n.child) {
+		if err := heap.marshalTree(buffer, codec, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restore adds the decoded trees to the empty heap as its root list.
+// Returns ErrFull if a bounded heap cannot take num values of the given total size.
+func (heap *FibHeap[t]) restore(roots []*node[t], num uint, size int) error {
+	if heap.full(num, size) {
+		return ErrFull
+	}
+
+	for _, root := range roots {
+		heap.restoreTree(root)
+		pushBack(&heap.roots, root)
+		if heap.min == nil || root.priority < heap.min.priority {
+			heap.min = root
+		}
+	}
+
+	if num > 0 {
+		heap.cond.Broadcast()
+	}
+
+	return nil
+}
+
+// restoredNode validates a decoded value and builds a detached node for it under parent.
+// seen holds the data decoded so far, to reject duplicates before anything is added to the heap.
+func (heap *FibHeap[t]) restoredNode(data t, priority float64, marked bool, parent *node[t], seen map[interface{}]bool) (*node[t], error) {
+	if err := heap.validate(data, priority); err != nil {
+		return nil, err
+	}
+	if seen[data] {
+		return nil, errors.New("Duplicate data is not allowed ")
+	}
+	seen[data] = true
+
+	n := heap.newNode()
+	n.data = data
+	n.priority = heap.toKey(priority)
+	n.size = heap.sizeOf(data)
+	n.marked = marked
+	n.parent = parent
+	if parent != nil && n.priority < parent.priority {
+		return nil, errors.New("Child priority is smaller than its parent priority")
+	}
+
+	if parent != nil {
+		pushBack(&parent.child, n)
+		parent.degree++
+	}
+
+	return n, nil
+}
+
+// restoreTree adds the decoded tree rooted at n to the index, the counters and the max heap.
+func (heap *FibHeap[t]) restoreTree(n *node[t]) {
+	heap.index[n.data] = n
+	heap.num++
+	heap.weight += n.size
+
+	if heap.maxHeap != nil {
+		binheap.Push(heap.maxHeap, n)
+	}
+
+	for child := n.child; child != nil; child = child.next(n.child) {
+		heap.restoreTree(child)
+	}
+}
+
+// treeDecoder reads the trees written by marshalTree into detached nodes,
+// so that a failure leaves the heap untouched.
+type treeDecoder[t any] struct {
+	heap   *FibHeap[t]
+	reader *bytes.Reader
+	codec  Codec[t]
+	left   uint64
+	size   int
+	seen   map[interface{}]bool
+}
+
+func (decoder *treeDecoder[t]) tree(parent *node[t]) (*node[t], error) {
+	if decoder.left == 0 {
+		return nil, ErrCorrupt
+	}
+	decoder.left--
+
+	var bits [8]byte
+	if _, err := io.ReadFull(decoder.reader, bits[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	marked, err := decoder.reader.ReadByte()
+	if err != nil || marked > 1 {
+		return nil, ErrCorrupt
+	}
+	degree, err := binary.ReadUvarint(decoder.reader)
+	if err != nil || degree > decoder.left {
+		return nil, ErrCorrupt
+	}
+	length, err := binary.ReadUvarint(decoder.reader)
+	if err != nil || length > uint64(decoder.reader.Len()) {
+		return nil, ErrCorrupt
+	}
+	payload := make([]byte, length)
+	decoder.reader.Read(payload)
+
+	data, err := decoder.codec.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	priority := math.Float64frombits(binary.BigEndian.Uint64(bits[:]))
+	n, err := decoder.heap.restoredNode(data, priority, marked == 1, parent, decoder.seen)
+	if err != nil {
+		return nil, err
+	}
+	decoder.size += n.size
+
+	for i := uint64(0); i < degree; i++ {
+		if _, err := decoder.tree(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
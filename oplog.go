@@ -0,0 +1,127 @@
+package fibheap
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// opLog is a FibHeap whose mutations are numbered and handed to a log as ops.
+// A mutation is applied and logged as one transaction: it only becomes visible once write succeeds,
+// and it is rolled back otherwise. DurableHeap and Primary embed it with their own write.
+type opLog[t any] struct {
+	heap   *FibHeap[t]
+	mutex  sync.Mutex
+	seq    uint64
+	closed bool
+	// write logs an op that already carries its sequence number.
+	write func(o op[t]) error
+	// committed runs with the mutex held after every committed mutation, if it is set.
+	committed func() error
+}
+
+// Num returns the total number of values in the heap.
+func (log *opLog[t]) Num() uint {
+	return log.heap.Num()
+}
+
+// Minimum returns the current minimum data and priority in the heap.
+// Returns -inf if the heap is empty.
+func (log *opLog[t]) Minimum() (data t, f float64) {
+	return log.heap.Minimum()
+}
+
+// GetPriority returns the priority of the value with the given data in the heap.
+// Returns -inf if the value is not found.
+func (log *opLog[t]) GetPriority(data t) (priority float64) {
+	return log.heap.GetPriority(data)
+}
+
+// Insert logs and inserts a new value with the given data and priority into the heap.
+// Returns an error if the insertion or the log write fails.
+func (log *opLog[t]) Insert(data t, priority float64) error {
+	return log.commit(func(tx *Tx[t]) (op[t], error) {
+		return op[t]{kind: opInsert, data: data, priority: priority}, tx.Insert(data, priority)
+	})
+}
+
+// ExtractMin extracts the current minimum data and priority from the heap, logging the removal of that value.
+// Returns -inf if the heap is empty, and an error if the log write fails.
+func (log *opLog[t]) ExtractMin() (data t, f float64, err error) {
+	err = log.commit(func(tx *Tx[t]) (op[t], error) {
+		data, f = tx.ExtractMin()
+		if math.IsInf(f, -1) {
+			return op[t]{}, nil
+		}
+		return op[t]{kind: opDelete, data: data}, nil
+	})
+	return data, f, err
+}
+
+// DecreasePriority logs and decreases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found, the priority is negative infinity or the log write fails.
+func (log *opLog[t]) DecreasePriority(data t, priority float64) error {
+	return log.commit(func(tx *Tx[t]) (op[t], error) {
+		return op[t]{kind: opDecrease, data: data, priority: priority}, tx.DecreasePriority(data, priority)
+	})
+}
+
+// IncreasePriority logs and increases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found, the priority is negative infinity or the log write fails.
+func (log *opLog[t]) IncreasePriority(data t, priority float64) error {
+	return log.commit(func(tx *Tx[t]) (op[t], error) {
+		return op[t]{kind: opIncrease, data: data, priority: priority}, tx.IncreasePriority(data, priority)
+	})
+}
+
+// Delete logs and removes the value with the given data from the heap.
+// Returns an error if the data is not found or the log write fails.
+func (log *opLog[t]) Delete(data t) error {
+	return log.commit(func(tx *Tx[t]) (op[t], error) {
+		return op[t]{kind: opDelete, data: data}, tx.Delete(data)
+	})
+}
+
+// Union logs and merges the values of the input heap into the heap as a single op.
+// Returns an error if any duplicate data are found in the target heap or the log write fails.
+func (log *opLog[t]) Union(anotherHeap *FibHeap[t]) error {
+	items := anotherHeap.items()
+	return log.commit(func(tx *Tx[t]) (op[t], error) {
+		for _, item := range items {
+			if err := tx.Insert(item.data, item.priority); err != nil {
+				return op[t]{}, errors.New("Duplicate data is found in the target heap")
+			}
+		}
+		return op[t]{kind: opUnion, items: items}, nil
+	})
+}
+
+// commit runs fn in a transaction of the heap and writes the op it returns with the next sequence number.
+// An op of kind zero means that fn made no change to log.
+func (log *opLog[t]) commit(fn func(tx *Tx[t]) (op[t], error)) error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	if log.closed {
+		return ErrClosed
+	}
+
+	err := log.heap.Update(func(tx *Tx[t]) error {
+		o, err := fn(tx)
+		if err != nil || o.kind == 0 {
+			return err
+		}
+
+		o.seq = log.seq + 1
+		if err := log.write(o); err != nil {
+			return err
+		}
+		log.seq = o.seq
+		return nil
+	})
+	if err != nil || log.committed == nil {
+		return err
+	}
+
+	return log.committed()
+}
@@ -0,0 +1,59 @@
+package fibheap
+
+// nodeSlab is the number of nodes allocated at once when the free list is empty.
+const nodeSlab = 64
+
+// SetNodePool keeps up to max nodes of removed values for reuse by later inserts, to cut allocations
+// and GC pressure under heavy churn. Zero, the default, disables recycling, and a negative max counts as zero.
+// While recycling is enabled, nodes are allocated in slabs; a slab stays in memory as long as any of its nodes is in use.
+func (heap *FibHeap[t]) SetNodePool(max int) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	if max < 0 {
+		max = 0
+	}
+
+	heap.poolMax = max
+	for heap.poolNum > heap.poolMax {
+		heap.pool = heap.pool.right
+		heap.poolNum--
+	}
+	if heap.poolMax == 0 {
+		heap.slab = nil
+	}
+}
+
+// newNode takes a node from the free list, or from the current slab if recycling is enabled.
+func (heap *FibHeap[t]) newNode() *node[t] {
+	if n := heap.pool; n != nil {
+		heap.pool = n.right
+		heap.poolNum--
+		n.right = nil
+		return n
+	}
+
+	if heap.poolMax == 0 {
+		return &node[t]{}
+	}
+
+	if len(heap.slab) == 0 {
+		heap.slab = make([]node[t], nodeSlab)
+	}
+	n := &heap.slab[0]
+	heap.slab = heap.slab[1:]
+	return n
+}
+
+// recycle clears a removed node, so that it does not keep its data or its neighbours alive,
+// and puts it on the free list unless the list is full.
+func (heap *FibHeap[t]) recycle(n *node[t]) {
+	*n = node[t]{}
+	if heap.poolNum >= heap.poolMax {
+		return
+	}
+
+	n.right = heap.pool
+	heap.pool = n
+	heap.poolNum++
+}
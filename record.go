@@ -0,0 +1,198 @@
+package fibheap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// recordHeader is the size of the length and CRC-32 that precede every record payload.
+const recordHeader = 8
+
+// errTorn is returned by readRecord when the input ends inside a record.
+var errTorn = errors.New("Record is incomplete")
+
+// appendRecord frames payload with its length and CRC-32, so that a reader can detect torn or corrupt records.
+func appendRecord(buffer []byte, payload []byte) []byte {
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(len(payload)))
+	buffer = binary.BigEndian.AppendUint32(buffer, crc32.ChecksumIEEE(payload))
+	return append(buffer, payload...)
+}
+
+// readRecord reads the payload of one record framed by appendRecord.
+// Returns io.EOF at a clean end of input, errTorn if the input ends inside the record,
+// or ErrCorrupt if the checksum does not match.
+func readRecord(reader io.Reader) ([]byte, error) {
+	var header [recordHeader]byte
+	if n, err := io.ReadFull(reader, header[:]); err != nil {
+		if n == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errTorn
+	}
+
+	payload, err := readDeclared(reader, int64(binary.BigEndian.Uint32(header[:4])))
+	if err != nil {
+		return nil, errTorn
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, ErrCorrupt
+	}
+
+	return payload, nil
+}
+
+// readDeclared reads the length bytes announced by a header. It grows its buffer as the bytes arrive
+// rather than allocating length up front, so that a corrupt header cannot make it allocate a huge buffer.
+// Returns the bytes read so far and an error if the input ends first.
+func readDeclared(reader io.Reader, length int64) ([]byte, error) {
+	var buffer bytes.Buffer
+	_, err := io.CopyN(&buffer, reader, length)
+	return buffer.Bytes(), err
+}
+
+// recordFollows reports whether a whole record with a matching checksum starts anywhere in content.
+// A record that runs past the end of the input is only torn if nothing valid follows its header;
+// otherwise its length field is damaged and the records after it are committed.
+// Empty records are never written, so that zeroed bytes do not pass for one.
+func recordFollows(content []byte) bool {
+	for i := 0; i+recordHeader <= len(content); i++ {
+		length := binary.BigEndian.Uint32(content[i:])
+		end := uint64(i+recordHeader) + uint64(length)
+		if length == 0 || end > uint64(len(content)) {
+			continue
+		}
+		if crc32.ChecksumIEEE(content[i+recordHeader:end]) == binary.BigEndian.Uint32(content[i+4:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// opKind identifies a logged mutation.
+type opKind byte
+
+const (
+	opInsert opKind = iota + 1
+	// opDelete removes a value, whether it was deleted or extracted as the minimum.
+	opDelete
+	opDecrease
+	opIncrease
+	opUnion
+	// opSnapshot carries a whole heap in a replication stream. It is never decoded by decodeOp.
+	opSnapshot
+)
+
+// op is a logged mutation with its sequence number. Priorities are the ones seen by callers.
+type op[t any] struct {
+	kind     opKind
+	seq      uint64
+	data     t
+	priority float64
+	items    []item[t]
+}
+
+// encodeOp encodes o, using codec for its data.
+func encodeOp[t any](codec Codec[t], o op[t]) ([]byte, error) {
+	buffer := []byte{byte(o.kind)}
+	buffer = binary.AppendUvarint(buffer, o.seq)
+
+	items := o.items
+	if o.kind != opUnion {
+		items = []item[t]{{data: o.data, priority: o.priority}}
+	} else {
+		buffer = binary.AppendUvarint(buffer, uint64(len(items)))
+	}
+
+	for _, item := range items {
+		payload, err := codec.Encode(item.data)
+		if err != nil {
+			return nil, err
+		}
+		buffer = binary.AppendUvarint(buffer, uint64(len(payload)))
+		buffer = append(buffer, payload...)
+		if o.kind != opDelete {
+			buffer = binary.BigEndian.AppendUint64(buffer, math.Float64bits(item.priority))
+		}
+	}
+
+	return buffer, nil
+}
+
+// decodeOp decodes a payload produced by encodeOp.
+func decodeOp[t any](codec Codec[t], payload []byte) (o op[t], err error) {
+	reader := bytes.NewReader(payload)
+	kind, err := reader.ReadByte()
+	if err != nil || kind < byte(opInsert) || kind > byte(opUnion) {
+		return o, ErrCorrupt
+	}
+	o.kind = opKind(kind)
+	if o.seq, err = binary.ReadUvarint(reader); err != nil {
+		return o, ErrCorrupt
+	}
+
+	count := uint64(1)
+	if o.kind == opUnion {
+		if count, err = binary.ReadUvarint(reader); err != nil || count > uint64(reader.Len()) {
+			return o, ErrCorrupt
+		}
+	}
+
+	items := make([]item[t], 0, count)
+	for i := uint64(0); i < count; i++ {
+		length, err := binary.ReadUvarint(reader)
+		if err != nil || length > uint64(reader.Len()) {
+			return o, ErrCorrupt
+		}
+		data, err := codec.Decode(payload[len(payload)-reader.Len():][:length])
+		if err != nil {
+			return o, err
+		}
+		reader.Seek(int64(length), io.SeekCurrent)
+
+		priority := 0.0
+		if o.kind != opDelete {
+			var bits [8]byte
+			if _, err := io.ReadFull(reader, bits[:]); err != nil {
+				return o, ErrCorrupt
+			}
+			priority = math.Float64frombits(binary.BigEndian.Uint64(bits[:]))
+		}
+		items = append(items, item[t]{data: data, priority: priority})
+	}
+	if reader.Len() != 0 {
+		return o, ErrCorrupt
+	}
+
+	if o.kind == opUnion {
+		o.items = items
+	} else {
+		o.data, o.priority = items[0].data, items[0].priority
+	}
+
+	return o, nil
+}
+
+// apply replays o into tx. Returns an error if the heap does not accept it.
+func (o op[t]) apply(tx *Tx[t]) error {
+	switch o.kind {
+	case opInsert:
+		return tx.Insert(o.data, o.priority)
+	case opDelete:
+		return tx.Delete(o.data)
+	case opDecrease:
+		return tx.DecreasePriority(o.data, o.priority)
+	case opIncrease:
+		return tx.IncreasePriority(o.data, o.priority)
+	case opUnion:
+		for _, item := range o.items {
+			if err := tx.Insert(item.data, item.priority); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package fibheap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrGap is returned by Follower.Apply when the stream skips a sequence number.
+var ErrGap = errors.New("Replication stream has a gap")
+
+// Primary is a FibHeap that streams its mutations to followers as ops with sequence numbers.
+// A mutation is applied and streamed as one transaction: it is rolled back if it cannot be written.
+// The stream uses the framing of the DurableHeap log, so every op carries its length and a CRC-32.
+type Primary[t any] struct {
+	opLog[t]
+	codec  Codec[t]
+	writer io.Writer
+}
+
+// NewPrimary creates an empty Primary that streams its ops to w, encoding data with codec,
+// or GobCodec if codec is nil. Writes to w block the mutation that makes them.
+func NewPrimary[t any](w io.Writer, codec Codec[t]) *Primary[t] {
+	if codec == nil {
+		codec = GobCodec[t]{}
+	}
+
+	primary := &Primary[t]{codec: codec, writer: w}
+	primary.heap = NewFibHeap[t]()
+	primary.heap.SetCodec(codec)
+	primary.write = primary.send
+	return primary
+}
+
+// Seq returns the sequence number of the last op streamed.
+func (primary *Primary[t]) Seq() uint64 {
+	primary.mutex.Lock()
+	defer primary.mutex.Unlock()
+
+	return primary.seq
+}
+
+// Snapshot streams the whole heap, so that followers that fell behind or joined late can resynchronize.
+func (primary *Primary[t]) Snapshot() error {
+	primary.mutex.Lock()
+	defer primary.mutex.Unlock()
+
+	if primary.closed {
+		return ErrClosed
+	}
+
+	heap, err := primary.heap.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	payload := binary.AppendUvarint([]byte{byte(opSnapshot)}, primary.seq)
+	_, err = primary.writer.Write(appendRecord(nil, append(payload, heap...)))
+	return err
+}
+
+// Close stops streaming, and closes the stream if it is an io.Closer so that followers see its end.
+// Returns ErrClosed if the primary was already closed.
+func (primary *Primary[t]) Close() error {
+	primary.mutex.Lock()
+	defer primary.mutex.Unlock()
+
+	if primary.closed {
+		return ErrClosed
+	}
+
+	primary.closed = true
+	primary.heap.Close()
+	if closer, ok := primary.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// send writes o to the stream as one record.
+func (primary *Primary[t]) send(o op[t]) error {
+	payload, err := encodeOp(primary.codec, o)
+	if err != nil {
+		return err
+	}
+
+	_, err = primary.writer.Write(appendRecord(nil, payload))
+	return err
+}
+
+// Follower replays the stream of a Primary into a FibHeap.
+type Follower[t any] struct {
+	heap  *FibHeap[t]
+	codec Codec[t]
+	mutex sync.Mutex
+	seq   uint64
+	stale bool
+}
+
+// NewFollower creates a Follower that replays into heap, decoding data with codec, or GobCodec if codec is nil.
+// heap should only be mutated by the Follower.
+func NewFollower[t any](heap *FibHeap[t], codec Codec[t]) *Follower[t] {
+	if codec == nil {
+		codec = GobCodec[t]{}
+	}
+
+	heap.SetCodec(codec)
+	return &Follower[t]{heap: heap, codec: codec}
+}
+
+// Seq returns the sequence number of the last op applied.
+func (follower *Follower[t]) Seq() uint64 {
+	follower.mutex.Lock()
+	defer follower.mutex.Unlock()
+
+	return follower.seq
+}
+
+// Apply reads the stream from r and replays it until r ends, returning nil at a clean end of the stream.
+// Ops that were already applied are skipped. On a skipped sequence number it returns an error wrapping ErrGap,
+// and then ignores the ops read by later calls until the stream carries a snapshot from Primary.Snapshot.
+// Returns ErrCorrupt or io.ErrUnexpectedEOF if the stream is damaged,
+// or the error of the heap if an op does not apply.
+func (follower *Follower[t]) Apply(r io.Reader) error {
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err == errTorn {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := follower.apply(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// apply replays one record of the stream. The lock is only held here, and not while
+// Apply waits for the next record, so that Seq does not block on a live stream.
+func (follower *Follower[t]) apply(payload []byte) error {
+	follower.mutex.Lock()
+	defer follower.mutex.Unlock()
+
+	if len(payload) > 0 && opKind(payload[0]) == opSnapshot {
+		return follower.resync(payload[1:])
+	}
+
+	o, err := decodeOp(follower.codec, payload)
+	if err != nil {
+		return err
+	}
+	if follower.stale || o.seq <= follower.seq {
+		return nil
+	}
+	if o.seq != follower.seq+1 {
+		follower.stale = true
+		return fmt.Errorf("%w: expected op %d but got op %d", ErrGap, follower.seq+1, o.seq)
+	}
+
+	if err := follower.heap.Update(o.apply); err != nil {
+		return err
+	}
+	follower.seq = o.seq
+	return nil
+}
+
+// resync replaces the values of the heap with the ones of a snapshot, in one step for readers of the heap.
+// A corrupt snapshot leaves the heap untouched.
+func (follower *Follower[t]) resync(payload []byte) error {
+	seq, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return ErrCorrupt
+	}
+
+	heap := follower.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if err := heap.replaceBinary(payload[n:]); err != nil {
+		return err
+	}
+
+	follower.seq = seq
+	follower.stale = false
+	return nil
+}
@@ -0,0 +1,171 @@
+package fibheap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync/atomic"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// lossyWriter drops the write with the given number, counting from 1.
+type lossyWriter struct {
+	io.WriteCloser
+	writes atomic.Int32
+	drop   int32
+}
+
+func (writer *lossyWriter) Write(p []byte) (int, error) {
+	if writer.writes.Add(1) == writer.drop {
+		return len(p), nil
+	}
+	return writer.WriteCloser.Write(p)
+}
+
+var _ = Describe("Tests of replication", func() {
+	var (
+		primary  *fibheap.Primary[int]
+		replica  *fibheap.FibHeap[int]
+		follower *fibheap.Follower[int]
+		gaps     chan error
+		done     chan error
+	)
+
+	start := func(writer io.WriteCloser, reader io.Reader) {
+		primary = fibheap.NewPrimary[int](writer, nil)
+		replica = fibheap.NewFibHeap[int]()
+		follower = fibheap.NewFollower(replica, nil)
+		gaps = make(chan error, 10)
+		done = make(chan error, 1)
+		go func() {
+			for {
+				err := follower.Apply(reader)
+				if errors.Is(err, fibheap.ErrGap) {
+					gaps <- err
+					continue
+				}
+				done <- err
+				return
+			}
+		}()
+	}
+
+	AfterEach(func() {
+		primary.Close()
+		primary = nil
+	})
+
+	It("Given a primary streaming over a pipe, when it is mutated, it should be mirrored by the follower.", func() {
+		reader, writer := io.Pipe()
+		start(writer, reader)
+
+		for i := 1; i <= 10; i++ {
+			Expect(primary.Insert(i, float64(i))).ShouldNot(HaveOccurred())
+		}
+		Expect(primary.Insert(1, 1)).Should(HaveOccurred())
+		data, _, err := primary.ExtractMin()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(data).Should(BeEquivalentTo(1))
+		Expect(primary.DecreasePriority(10, 0)).ShouldNot(HaveOccurred())
+		Expect(primary.IncreasePriority(2, 20)).ShouldNot(HaveOccurred())
+		Expect(primary.Delete(3)).ShouldNot(HaveOccurred())
+		another := fibheap.NewFibHeap[int]()
+		another.Insert(11, -1)
+		Expect(primary.Union(another)).ShouldNot(HaveOccurred())
+
+		Expect(primary.Close()).ShouldNot(HaveOccurred())
+		Eventually(done).Should(Receive(BeNil()))
+
+		Expect(follower.Seq()).Should(Equal(primary.Seq()))
+		Expect(replica.Num()).Should(Equal(primary.Num()))
+		for i := 1; i <= 11; i++ {
+			Expect(replica.GetPriority(i)).Should(Equal(primary.GetPriority(i)))
+		}
+	})
+
+	It("Given a stream that lost an op, when the follower reads past it, it should report the gap and resynchronize from a snapshot.", func() {
+		reader, writer := io.Pipe()
+		start(&lossyWriter{WriteCloser: writer, drop: 2}, reader)
+
+		primary.Insert(1, 1)
+		primary.Insert(2, 2)
+		primary.Insert(3, 3)
+		Eventually(gaps).Should(Receive(MatchError(ContainSubstring("expected op 2 but got op 3"))))
+
+		primary.Insert(4, 4)
+		Expect(primary.Snapshot()).ShouldNot(HaveOccurred())
+		primary.DecreasePriority(4, 0)
+		primary.Close()
+		Eventually(done).Should(Receive(BeNil()))
+
+		Expect(follower.Seq()).Should(BeEquivalentTo(5))
+		expected := []int{4, 1, 2, 3}
+		for _, e := range expected {
+			data, _ := replica.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(e))
+		}
+	})
+
+	It("Given a follower waiting on a live stream, when call Seq api, it should return the last op applied.", func() {
+		reader, writer := io.Pipe()
+		start(writer, reader)
+
+		primary.Insert(1, 1)
+		Eventually(replica.Num).Should(BeEquivalentTo(1))
+
+		seq := make(chan uint64, 1)
+		go func() { seq <- follower.Seq() }()
+		Eventually(seq).Should(Receive(BeEquivalentTo(1)))
+
+		primary.Close()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("Given a follower, when the stream carries a corrupt snapshot, it should keep its values and not report them as extracted.", func() {
+		var stream bytes.Buffer
+		primary = fibheap.NewPrimary[int](&stream, nil)
+		replica = fibheap.NewFibHeap[int]()
+		follower = fibheap.NewFollower(replica, nil)
+		extracted := 0
+		replica.SetHooks(fibheap.Hooks[int]{OnExtract: func(int, float64) { extracted++ }})
+
+		primary.Insert(1, 1)
+		primary.Insert(2, 2)
+		Expect(follower.Apply(&stream)).ShouldNot(HaveOccurred())
+
+		Expect(primary.Snapshot()).ShouldNot(HaveOccurred())
+		snapshot := bytes.Clone(stream.Bytes())
+		payload := snapshot[8 : len(snapshot)-1]
+		corrupt := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+		corrupt = binary.BigEndian.AppendUint32(corrupt, crc32.ChecksumIEEE(payload))
+		corrupt = append(corrupt, payload...)
+		Expect(follower.Apply(bytes.NewReader(corrupt))).Should(MatchError(fibheap.ErrCorrupt))
+		Expect(replica.Num()).Should(BeEquivalentTo(2))
+		Expect(follower.Seq()).Should(BeEquivalentTo(2))
+
+		primary.ExtractMin()
+		stream.Reset()
+		Expect(primary.Snapshot()).ShouldNot(HaveOccurred())
+		Expect(follower.Apply(&stream)).ShouldNot(HaveOccurred())
+		Expect(replica.Num()).Should(BeEquivalentTo(1))
+		Expect(follower.Seq()).Should(BeEquivalentTo(3))
+		Expect(extracted).Should(BeZero())
+	})
+
+	It("Given a follower, when the stream ends inside an op, it should return an error.", func() {
+		reader, writer := io.Pipe()
+		start(writer, reader)
+
+		go func() {
+			writer.Write([]byte{0, 0, 0, 9, 1})
+			writer.Close()
+		}()
+		Eventually(done).Should(Receive(MatchError(io.ErrUnexpectedEOF)))
+	})
+})
@@ -0,0 +1,149 @@
+package fibheap
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrDeadLetter is returned by Retry when a value has used up its attempts and was moved to the dead-letter heap.
+var ErrDeadLetter = errors.New("Value is moved to the dead-letter heap")
+
+// RetryPolicy configures Retry.
+type RetryPolicy struct {
+	// MaxAttempts is the number of retries allowed before a value is dead-lettered; zero allows any number.
+	MaxAttempts int
+	// Backoff returns the delay before the given attempt, counting from 1.
+	Backoff func(attempt int) time.Duration
+}
+
+type retryState struct {
+	attempts int
+	err      error
+}
+
+// ExponentialBackoff returns a backoff that starts at base and doubles on every attempt, up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		return delay
+	}
+}
+
+// SetRetryPolicy sets the policy used by Retry.
+func (heap *FibHeap[t]) SetRetryPolicy(policy RetryPolicy) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	heap.retry = policy
+}
+
+// Retry records a failed attempt at processing the value with the given data, which must have been
+// extracted or leased, and puts it back with the priority TimePriority(now + Backoff(attempts)).
+// Once the value exceeds MaxAttempts, it is moved to the dead-letter heap instead and ErrDeadLetter is returned.
+// The value is re-queued as by Insert, so the overflow policy applies.
+// Returns an error if the value is still queued or cannot be re-queued or dead-lettered, or ErrClosed if the heap is closed.
+func (heap *FibHeap[t]) Retry(data t, cause error) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	if _, exists := heap.index[data]; exists {
+		return errors.New("Value is still queued")
+	}
+
+	if l, leased := heap.leases[data]; leased {
+		heap.endLease(data, l)
+	}
+
+	state, exists := heap.attempts[data]
+	if !exists {
+		state = new(retryState)
+		heap.attempts[data] = state
+	}
+	state.attempts++
+	state.err = cause
+
+	now := heap.clock.Now()
+	if heap.retry.MaxAttempts > 0 && state.attempts > heap.retry.MaxAttempts {
+		if err := heap.deadLetterHeap().Insert(data, TimePriority(now)); err != nil {
+			return err
+		}
+		return ErrDeadLetter
+	}
+
+	var delay time.Duration
+	if heap.retry.Backoff != nil {
+		delay = heap.retry.Backoff(state.attempts)
+	}
+
+	return heap.insertWait(context.Background(), data, heap.toKey(TimePriority(now.Add(delay))))
+}
+
+// Attempts returns how many times Retry was called for the value with the given data, and the last error passed to it.
+func (heap *FibHeap[t]) Attempts(data t) (attempts int, lastErr error) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	if state, exists := heap.attempts[data]; exists {
+		return state.attempts, state.err
+	}
+
+	return 0, nil
+}
+
+// ResetAttempts forgets the attempts recorded for the value with the given data, usually once it has been processed.
+func (heap *FibHeap[t]) ResetAttempts(data t) {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	delete(heap.attempts, data)
+}
+
+// DeadLetters returns the heap holding the values that exceeded MaxAttempts,
+// prioritized by the Unix time they were dead-lettered at.
+func (heap *FibHeap[t]) DeadLetters() *FibHeap[t] {
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	return heap.deadLetterHeap()
+}
+
+// Replay moves the value with the given data from the dead-letter heap back into the heap,
+// with its attempts reset and a priority of TimePriority(now). The value is inserted as by Insert,
+// so the overflow policy applies, and it stays dead-lettered if it cannot be inserted.
+// Returns an error if the value is not dead-lettered or cannot be inserted.
+func (heap *FibHeap[t]) Replay(data t) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	priority := heap.deadLetterHeap().ExtractPriority(data)
+	if math.IsInf(priority, -1) {
+		return errors.New("Tag is not found")
+	}
+
+	if err := heap.insertWait(context.Background(), data, heap.toKey(TimePriority(heap.clock.Now()))); err != nil {
+		heap.deadLetterHeap().Insert(data, priority)
+		return err
+	}
+
+	delete(heap.attempts, data)
+	return nil
+}
+
+func (heap *FibHeap[t]) deadLetterHeap() *FibHeap[t] {
+	if heap.deadLetters == nil {
+		heap.deadLetters = NewFibHeap[t]()
+	}
+	return heap.deadLetters
+}
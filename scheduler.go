@@ -0,0 +1,191 @@
+package fibheap
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Scheduler runs recurring jobs. Each job is a value kept in a DelayQueue at its next run,
+// and taking an occurrence with Next or PopReady queues the following one.
+type Scheduler[t any] struct {
+	queue *DelayQueue[t]
+	mutex sync.Mutex
+	jobs  map[interface{}]*job
+}
+
+type job struct {
+	schedule Schedule
+	jitter   time.Duration
+	run      time.Time
+	paused   bool
+}
+
+// NewScheduler creates a scheduler without jobs, using the system clock.
+func NewScheduler[t any]() *Scheduler[t] {
+	scheduler := new(Scheduler[t])
+	scheduler.queue = NewDelayQueue[t]()
+	scheduler.jobs = make(map[interface{}]*job)
+
+	return scheduler
+}
+
+// SetClock replaces the clock used to compute and wait for runs.
+func (scheduler *Scheduler[t]) SetClock(clock Clock) {
+	scheduler.queue.SetClock(clock)
+}
+
+// Add registers a recurring job and queues its first run after now.
+// Each run is delayed by a random duration below jitter, without moving the runs that follow.
+// Returns an error if the job is already registered.
+func (scheduler *Scheduler[t]) Add(data t, schedule Schedule, jitter time.Duration) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if _, exists := scheduler.jobs[data]; exists {
+		return errors.New("Duplicate data is not allowed")
+	}
+
+	j := &job{schedule: schedule, jitter: jitter}
+	if err := scheduler.enqueue(data, j, scheduler.now()); err != nil {
+		return err
+	}
+
+	scheduler.jobs[data] = j
+	return nil
+}
+
+// Pause stops queueing runs of a job until Resume is called.
+// Returns an error if the job is not registered or already paused.
+func (scheduler *Scheduler[t]) Pause(data t) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	j, exists := scheduler.jobs[data]
+	if !exists {
+		return errors.New("Value is not found")
+	}
+	if j.paused {
+		return errors.New("Job is already paused")
+	}
+
+	j.paused = true
+	scheduler.queue.Cancel(data)
+	return nil
+}
+
+// Resume queues the first run after now of a paused job.
+// Returns an error if the job is not registered or not paused.
+func (scheduler *Scheduler[t]) Resume(data t) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	j, exists := scheduler.jobs[data]
+	if !exists {
+		return errors.New("Value is not found")
+	}
+	if !j.paused {
+		return errors.New("Job is not paused")
+	}
+
+	j.paused = false
+	return scheduler.enqueue(data, j, scheduler.now())
+}
+
+// Delete removes a recurring job along with its queued run.
+// Returns an error if the job is not registered.
+func (scheduler *Scheduler[t]) Delete(data t) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if _, exists := scheduler.jobs[data]; !exists {
+		return errors.New("Value is not found")
+	}
+
+	delete(scheduler.jobs, data)
+	scheduler.queue.Cancel(data)
+	return nil
+}
+
+// NextRun returns when the next run of a job is queued for, jitter included, and false if it is not queued.
+func (scheduler *Scheduler[t]) NextRun(data t) (time.Time, bool) {
+	heap := scheduler.queue.heap
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	at, exists := scheduler.queue.deadlines[data]
+	return at, exists
+}
+
+// Next blocks until the earliest run is due, queues the following run of its job and returns
+// the job together with the time the run was scheduled for, without jitter.
+// Returns the context error if ctx is done first.
+func (scheduler *Scheduler[t]) Next(ctx context.Context) (data t, run time.Time, err error) {
+	for {
+		data, err = scheduler.queue.WaitNext(ctx)
+		if err != nil {
+			return data, run, err
+		}
+
+		if run, ok := scheduler.advance(data); ok {
+			return data, run, nil
+		}
+	}
+}
+
+// PopReady returns every job with a run due at or before now, queueing their following runs.
+func (scheduler *Scheduler[t]) PopReady(now time.Time) []t {
+	var ready []t
+	for _, data := range scheduler.queue.PopReady(now) {
+		if _, ok := scheduler.advance(data); ok {
+			ready = append(ready, data)
+		}
+	}
+
+	return ready
+}
+
+// advance queues the run following the one just taken for a job, skipping runs that were missed.
+// Returns false if the job was paused or deleted while its run was being taken.
+func (scheduler *Scheduler[t]) advance(data t) (time.Time, bool) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	j, exists := scheduler.jobs[data]
+	if !exists || j.paused {
+		return time.Time{}, false
+	}
+
+	run := j.run
+	after := run
+	if now := scheduler.now(); j.schedule.Next(after).Before(now) {
+		after = now
+	}
+	scheduler.enqueue(data, j, after)
+
+	return run, true
+}
+
+func (scheduler *Scheduler[t]) enqueue(data t, j *job, after time.Time) error {
+	j.run = j.schedule.Next(after)
+	if j.run.IsZero() {
+		return errors.New("Schedule has no next run")
+	}
+
+	at := j.run
+	if j.jitter > 0 {
+		at = at.Add(rand.N(j.jitter))
+	}
+
+	return scheduler.queue.Schedule(data, at)
+}
+
+func (scheduler *Scheduler[t]) now() time.Time {
+	heap := scheduler.queue.heap
+	heap.mutex.Lock()
+	defer heap.mutex.Unlock()
+
+	return heap.clock.Now()
+}
@@ -0,0 +1,142 @@
+package fibheap_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tests of cron", func() {
+	base := time.Date(2024, 1, 1, 10, 30, 15, 0, time.UTC) // a Monday
+
+	It("Given cron expressions, when call Next api, it should return the first matching minute after the given time.", func() {
+		cases := map[string]time.Time{
+			"* * * * *":      time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC),
+			"*/15 * * * *":   time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC),
+			"0 9 * * *":      time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+			"0 9-17/4 * * *": time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC),
+			"5,10 0 1 * *":   time.Date(2024, 2, 1, 0, 5, 0, 0, time.UTC),
+			"0 0 * * 0":      time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC),
+			"0 0 * * 7":      time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC),
+			"0 0 29 2 *":     time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			"0 0 13 * 5":     time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			"30 10 1 1 *":    time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC),
+		}
+
+		for expr, expected := range cases {
+			schedule, err := fibheap.ParseCron(expr)
+			Expect(err).ShouldNot(HaveOccurred(), expr)
+			Expect(schedule.Next(base)).Should(Equal(expected), expr)
+		}
+	})
+
+	It("Given invalid cron expressions, when call ParseCron api, it should return error.", func() {
+		for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := fibheap.ParseCron(expr)
+			Expect(err).Should(HaveOccurred(), expr)
+		}
+	})
+})
+
+var _ = Describe("Tests of scheduler", func() {
+	var (
+		scheduler *fibheap.Scheduler[string]
+		clock     *fakeClock
+	)
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		scheduler = fibheap.NewScheduler[string]()
+		scheduler.SetClock(clock)
+	})
+
+	AfterEach(func() {
+		scheduler = nil
+	})
+
+	It("Given a scheduler with an interval job, when call PopReady api, it should return the job and queue its next run.", func() {
+		start := clock.Now()
+		Expect(scheduler.Add("job", fibheap.Every(time.Minute), 0)).ShouldNot(HaveOccurred())
+		Expect(scheduler.Add("job", fibheap.Every(time.Minute), 0)).Should(HaveOccurred())
+
+		Expect(scheduler.PopReady(start)).Should(BeEmpty())
+		clock.Advance(time.Minute)
+		Expect(scheduler.PopReady(clock.Now())).Should(Equal([]string{"job"}))
+
+		run, ok := scheduler.NextRun("job")
+		Expect(ok).Should(BeTrue())
+		Expect(run).Should(Equal(start.Add(2 * time.Minute)))
+	})
+
+	It("Given a scheduler with a job whose runs were missed, when call PopReady api, it should return the job once and skip the missed runs.", func() {
+		start := clock.Now()
+		scheduler.Add("job", fibheap.Every(time.Minute), 0)
+
+		clock.Advance(10*time.Minute + 30*time.Second)
+		Expect(scheduler.PopReady(clock.Now())).Should(Equal([]string{"job"}))
+		Expect(scheduler.PopReady(clock.Now())).Should(BeEmpty())
+
+		run, _ := scheduler.NextRun("job")
+		Expect(run).Should(Equal(start.Add(11*time.Minute + 30*time.Second)))
+	})
+
+	It("Given a scheduler with a jittered job, when its runs are queued, it should delay them by less than the jitter.", func() {
+		start := clock.Now()
+		scheduler.Add("job", fibheap.Every(time.Hour), time.Minute)
+
+		for i := 1; i <= 20; i++ {
+			run, _ := scheduler.NextRun("job")
+			scheduled := start.Add(time.Duration(i) * time.Hour)
+			Expect(run).Should(BeTemporally(">=", scheduled))
+			Expect(run).Should(BeTemporally("<", scheduled.Add(time.Minute)))
+			Expect(scheduler.PopReady(scheduled.Add(time.Minute))).Should(Equal([]string{"job"}))
+		}
+	})
+
+	It("Given a scheduler with a job, when call Pause, Resume and Delete apis, it should stop and restart its runs.", func() {
+		scheduler.Add("job", fibheap.Every(time.Minute), 0)
+		Expect(scheduler.Pause("job")).ShouldNot(HaveOccurred())
+		Expect(scheduler.Pause("job")).Should(HaveOccurred())
+
+		clock.Advance(time.Hour)
+		Expect(scheduler.PopReady(clock.Now())).Should(BeEmpty())
+
+		Expect(scheduler.Resume("job")).ShouldNot(HaveOccurred())
+		Expect(scheduler.Resume("job")).Should(HaveOccurred())
+		run, _ := scheduler.NextRun("job")
+		Expect(run).Should(Equal(clock.Now().Add(time.Minute)))
+
+		Expect(scheduler.Delete("job")).ShouldNot(HaveOccurred())
+		Expect(scheduler.Delete("job")).Should(HaveOccurred())
+		_, ok := scheduler.NextRun("job")
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("Given a scheduler with a cron job, when call Next api, it should wait for the run and return its scheduled time.", func() {
+		schedule, _ := fibheap.ParseCron("0 * * * *")
+		scheduler.Add("hourly", schedule, 0)
+
+		type occurrence struct {
+			data string
+			run  time.Time
+		}
+		done := make(chan occurrence)
+		go func() {
+			defer GinkgoRecover()
+			data, run, err := scheduler.Next(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			done <- occurrence{data, run}
+		}()
+
+		Eventually(clock.Pending).Should(Equal(1))
+		clock.Advance(time.Hour)
+		Eventually(done).Should(Receive(Equal(occurrence{"hourly", clock.Now()})))
+
+		run, _ := scheduler.NextRun("hourly")
+		Expect(run).Should(Equal(clock.Now().Add(time.Hour)))
+	})
+})
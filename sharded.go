@@ -0,0 +1,195 @@
+package fibheap
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedHeap spreads its values over several FibHeap shards so that concurrent producers
+// and consumers rarely contend on the same mutex. ExtractMin samples a few shards and pops
+// the best of their minimums, so it returns an approximate minimum of the whole heap.
+type ShardedHeap[t any] struct {
+	shards  []*FibHeap[t]
+	choices int
+	index   sync.Map
+	num     atomic.Int64
+}
+
+// NewShardedHeap creates a heap made of the given number of shards.
+// ExtractMin compares the minimums of choices randomly picked shards: two is a good default,
+// and larger values trade throughput for accuracy, up to an exact minimum when choices equals shards.
+func NewShardedHeap[t any](shards, choices int) *ShardedHeap[t] {
+	if shards < 1 {
+		shards = 1
+	}
+	if choices < 1 {
+		choices = 1
+	}
+	if choices > shards {
+		choices = shards
+	}
+
+	heap := new(ShardedHeap[t])
+	heap.shards = make([]*FibHeap[t], shards)
+	for i := range heap.shards {
+		heap.shards[i] = NewFibHeap[t]()
+	}
+	heap.choices = choices
+
+	return heap
+}
+
+// Num returns the total number of values in the heap.
+func (heap *ShardedHeap[t]) Num() uint {
+	return uint(heap.num.Load())
+}
+
+// Insert inserts a new value with the given data and priority into a random shard.
+// Returns an error if the insertion fails.
+func (heap *ShardedHeap[t]) Insert(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+
+	shard := rand.IntN(len(heap.shards))
+	if _, exists := heap.index.LoadOrStore(data, shard); exists {
+		return errors.New("Duplicate data is not allowed")
+	}
+
+	heap.num.Add(1)
+	if err := heap.shards[shard].Insert(data, priority); err != nil {
+		heap.num.Add(-1)
+		heap.index.Delete(data)
+		return err
+	}
+
+	return nil
+}
+
+// Minimum returns the exact minimum data and priority across all shards.
+// Returns -inf if the heap is empty.
+func (heap *ShardedHeap[t]) Minimum() (data t, f float64) {
+	f = math.Inf(-1)
+	for _, shard := range heap.shards {
+		d, p := shard.Minimum()
+		if !math.IsInf(p, -1) && (math.IsInf(f, -1) || p < f) {
+			data, f = d, p
+		}
+	}
+
+	return data, f
+}
+
+// ExtractMin extracts the best minimum among a random sample of shards and returns its data and priority.
+// Returns nil/-inf if the heap is empty.
+func (heap *ShardedHeap[t]) ExtractMin() (data t, f float64) {
+	for heap.num.Load() > 0 {
+		shard := heap.sample()
+		if shard == nil {
+			shard = heap.scan()
+		}
+		if shard == nil {
+			break
+		}
+
+		data, f = shard.ExtractMin()
+		if math.IsInf(f, -1) {
+			continue
+		}
+
+		heap.index.Delete(data)
+		heap.num.Add(-1)
+		return data, f
+	}
+
+	return data, math.Inf(-1)
+}
+
+// DecreasePriority decreases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found or the priority is negative infinity.
+func (heap *ShardedHeap[t]) DecreasePriority(data t, priority float64) error {
+	shard, err := heap.route(data)
+	if err != nil {
+		return err
+	}
+
+	return shard.DecreasePriority(data, priority)
+}
+
+// IncreasePriority increases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found or the priority is negative infinity.
+func (heap *ShardedHeap[t]) IncreasePriority(data t, priority float64) error {
+	shard, err := heap.route(data)
+	if err != nil {
+		return err
+	}
+
+	return shard.IncreasePriority(data, priority)
+}
+
+// GetPriority returns the priority of the value with the given data in the heap.
+// Returns -inf if the value is not found.
+func (heap *ShardedHeap[t]) GetPriority(data t) (priority float64) {
+	shard, err := heap.route(data)
+	if err != nil {
+		return math.Inf(-1)
+	}
+
+	return shard.GetPriority(data)
+}
+
+// Delete removes the value with the given data from the heap.
+// Returns an error if the data is not found.
+func (heap *ShardedHeap[t]) Delete(data t) error {
+	shard, err := heap.route(data)
+	if err != nil {
+		return err
+	}
+
+	if err := shard.Delete(data); err != nil {
+		return err
+	}
+
+	heap.index.Delete(data)
+	heap.num.Add(-1)
+	return nil
+}
+
+func (heap *ShardedHeap[t]) route(data t) (*FibHeap[t], error) {
+	shard, exists := heap.index.Load(data)
+	if !exists {
+		return nil, errors.New("Value is not found")
+	}
+
+	return heap.shards[shard.(int)], nil
+}
+
+// sample returns the non-empty shard with the smallest minimum among heap.choices distinct shards,
+// starting from a random one.
+func (heap *ShardedHeap[t]) sample() *FibHeap[t] {
+	var best *FibHeap[t]
+	min := math.Inf(1)
+	start := rand.IntN(len(heap.shards))
+	for i := 0; i < heap.choices; i++ {
+		shard := heap.shards[(start+i)%len(heap.shards)]
+		if _, priority := shard.Minimum(); !math.IsInf(priority, -1) && (best == nil || priority < min) {
+			best, min = shard, priority
+		}
+	}
+
+	return best
+}
+
+// scan returns the first non-empty shard, for when sampling only hit empty ones.
+func (heap *ShardedHeap[t]) scan() *FibHeap[t] {
+	for _, shard := range heap.shards {
+		if shard.Num() > 0 {
+			return shard
+		}
+	}
+
+	return nil
+}
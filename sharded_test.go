@@ -0,0 +1,141 @@
+package fibheap_test
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Run with -cpu 1,2,4,8 to see how each heap scales with GOMAXPROCS.
+func BenchmarkFibHeapParallel(b *testing.B) {
+	heap := fibheap.NewFibHeap[int]()
+	var next atomic.Int64
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			data := int(next.Add(1))
+			heap.Insert(data, rand.Float64())
+			heap.ExtractMin()
+		}
+	})
+}
+
+func BenchmarkShardedHeapParallel(b *testing.B) {
+	heap := fibheap.NewShardedHeap[int](16, 2)
+	var next atomic.Int64
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			data := int(next.Add(1))
+			heap.Insert(data, rand.Float64())
+			heap.ExtractMin()
+		}
+	})
+}
+
+var _ = Describe("Tests of shardedHeap", func() {
+	var heap *fibheap.ShardedHeap[int]
+
+	BeforeEach(func() {
+		heap = fibheap.NewShardedHeap[int](8, 2)
+	})
+
+	AfterEach(func() {
+		heap = nil
+	})
+
+	It("Given an empty shardedHeap, when call ExtractMin api, it should return -inf.", func() {
+		_, priority := heap.ExtractMin()
+		Expect(priority).Should(BeEquivalentTo(math.Inf(-1)))
+		Expect(heap.Num()).Should(BeEquivalentTo(0))
+	})
+
+	It("Given a shardedHeap, when Insert values with same data, it should return an error.", func() {
+		Expect(heap.Insert(1, 1)).ShouldNot(HaveOccurred())
+		Expect(heap.Insert(1, 2)).Should(HaveOccurred())
+		Expect(heap.Insert(2, math.Inf(-1))).Should(HaveOccurred())
+		Expect(heap.Num()).Should(BeEquivalentTo(1))
+	})
+
+	It("Given a shardedHeap inserted multiple values, when call Minimum api, it should return the exact minimum.", func() {
+		min := math.Inf(1)
+		for i := 0; i < 1000; i++ {
+			priority := rand.Float64()
+			heap.Insert(i, priority)
+			min = math.Min(min, priority)
+		}
+
+		_, priority := heap.Minimum()
+		Expect(priority).Should(BeEquivalentTo(min))
+	})
+
+	It("Given a shardedHeap with as many choices as shards, when call ExtractMin api, it should extract values in order.", func() {
+		heap = fibheap.NewShardedHeap[int](4, 4)
+		for i := 0; i < 1000; i++ {
+			heap.Insert(i, rand.Float64())
+		}
+
+		_, lastKey := heap.Minimum()
+		for heap.Num() > 0 {
+			_, priority := heap.ExtractMin()
+			Expect(priority).Should(BeNumerically(">=", lastKey))
+			lastKey = priority
+		}
+	})
+
+	It("Given a shardedHeap inserted multiple values, when call DecreasePriority, IncreasePriority and Delete apis, it should route them to the owning shard.", func() {
+		for i := 0; i < 100; i++ {
+			heap.Insert(i, float64(i))
+		}
+
+		Expect(heap.DecreasePriority(50, -1)).ShouldNot(HaveOccurred())
+		Expect(heap.IncreasePriority(0, 1000)).ShouldNot(HaveOccurred())
+		Expect(heap.GetPriority(50)).Should(BeEquivalentTo(-1))
+		Expect(heap.GetPriority(0)).Should(BeEquivalentTo(1000))
+		Expect(heap.Delete(10)).ShouldNot(HaveOccurred())
+		Expect(heap.Delete(10)).Should(HaveOccurred())
+		Expect(heap.DecreasePriority(1000, 1)).Should(HaveOccurred())
+		Expect(heap.Num()).Should(BeEquivalentTo(99))
+
+		data, _ := heap.Minimum()
+		Expect(data).Should(BeEquivalentTo(50))
+	})
+
+	It("Given a shardedHeap shared by many goroutines, when values are inserted and extracted concurrently, it should extract every value exactly once.", func() {
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					heap.Insert(w*1000+i, rand.Float64())
+				}
+			}(w)
+		}
+		wg.Wait()
+		Expect(heap.Num()).Should(BeEquivalentTo(8000))
+
+		var seen sync.Map
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for heap.Num() > 0 {
+					if data, priority := heap.ExtractMin(); !math.IsInf(priority, -1) {
+						_, loaded := seen.LoadOrStore(data, true)
+						Expect(loaded).Should(BeFalse())
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(heap.Num()).Should(BeEquivalentTo(0))
+	})
+})
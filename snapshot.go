@@ -0,0 +1,94 @@
+package fibheap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// snapshotMagic starts every snapshot written by SaveSnapshot.
+const snapshotMagic = "FIBH"
+
+// snapshotVersion is the version of the format written by SaveSnapshot.
+const snapshotVersion = 1
+
+// snapshotHeader is the size of the magic, the version, the value count and the body length.
+const snapshotHeader = len(snapshotMagic) + 1 + 8 + 8
+
+// SaveSnapshot writes a point-in-time snapshot of the heap to w.
+// The snapshot is taken under the heap lock, but written to w after the lock is released.
+// It holds a format version, the number of values, the heap as written by MarshalBinary and a CRC-32 of all of them.
+// Leases, deadlines and context bindings are not captured.
+func (heap *FibHeap[t]) SaveSnapshot(w io.Writer) error {
+	heap.mutex.Lock()
+	heap.expire()
+	num := heap.num
+	body, err := heap.marshalBinary()
+	heap.unlock()
+
+	if err != nil {
+		return err
+	}
+
+	snapshot := make([]byte, 0, snapshotHeader+len(body)+4)
+	snapshot = append(snapshot, snapshotMagic...)
+	snapshot = append(snapshot, snapshotVersion)
+	snapshot = binary.BigEndian.AppendUint64(snapshot, uint64(num))
+	snapshot = binary.BigEndian.AppendUint64(snapshot, uint64(len(body)))
+	snapshot = append(snapshot, body...)
+	snapshot = binary.BigEndian.AppendUint32(snapshot, crc32.ChecksumIEEE(snapshot))
+
+	_, err = w.Write(snapshot)
+	return err
+}
+
+// LoadSnapshot restores a snapshot written by SaveSnapshot into the heap, reading exactly one snapshot from r.
+// The heap must have been created by NewFibHeap and be empty.
+// Returns an error wrapping ErrCorrupt that describes the problem if the snapshot is truncated or damaged,
+// and the errors of UnmarshalBinary otherwise.
+func (heap *FibHeap[t]) LoadSnapshot(r io.Reader) error {
+	header := make([]byte, snapshotHeader)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: snapshot header is truncated", ErrCorrupt)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: input is not a snapshot", ErrCorrupt)
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return fmt.Errorf("%w: snapshot version %d is not supported", ErrCorrupt, version)
+	}
+	count := binary.BigEndian.Uint64(header[len(snapshotMagic)+1:])
+	length := binary.BigEndian.Uint64(header[len(snapshotMagic)+9:])
+
+	if length > math.MaxInt64 {
+		return fmt.Errorf("%w: snapshot body length %d is invalid", ErrCorrupt, length)
+	}
+
+	body, err := readDeclared(r, int64(length))
+	if err != nil {
+		return fmt.Errorf("%w: snapshot body is truncated after %d of %d bytes", ErrCorrupt, len(body), length)
+	}
+	var checksum [4]byte
+	if _, err := io.ReadFull(r, checksum[:]); err != nil {
+		return fmt.Errorf("%w: snapshot checksum is truncated", ErrCorrupt)
+	}
+
+	crc := crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, body)
+	if crc != binary.BigEndian.Uint32(checksum[:]) {
+		return fmt.Errorf("%w: snapshot checksum does not match", ErrCorrupt)
+	}
+	// The body starts with its version byte and then its own count of values.
+	if len(body) == 0 {
+		return fmt.Errorf("%w: snapshot body is empty", ErrCorrupt)
+	}
+	if num, n := binary.Uvarint(body[1:]); n <= 0 || num != count {
+		return fmt.Errorf("%w: snapshot holds %d values, not %d", ErrCorrupt, num, count)
+	}
+
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	return heap.unmarshalBinary(body)
+}
@@ -0,0 +1,520 @@
+package fibheap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// maxRuns is the number of runs on disk above which a SpillHeap merges them into one.
+const maxRuns = 64
+
+// SpillHeap is a priority queue for more values than fit in memory.
+// It keeps up to a limit of the smallest values in a FibHeap, and spills the larger half of them
+// to a sorted run in a temporary file whenever the limit is reached. ExtractMin merges the heap with the runs.
+// Spilled values are indexed by data, so that data stays unique and spilled values can be deleted or reprioritized:
+// the record of such a value is left in its run and skipped when the run is read, and a reprioritized value moves to memory.
+type SpillHeap[t any] struct {
+	mutex   sync.Mutex
+	memory  *FibHeap[t]
+	runs    *FibHeap[*spillRun[t]]
+	spilled map[interface{}]spillEntry[t]
+	limit   uint
+	dir     string
+	codec   Codec[t]
+	err     error
+	closed  bool
+}
+
+// spillEntry locates a spilled value.
+type spillEntry[t any] struct {
+	run      *spillRun[t]
+	priority float64
+}
+
+// spillRun is a sorted run of values in a temporary file, read one value ahead.
+// offset and length locate the record of the head in the file.
+// origin is the run itself, or the run read by a cursor.
+type spillRun[t any] struct {
+	file   *os.File
+	reader *bufio.Reader
+	origin *spillRun[t]
+	owned  bool
+	head   item[t]
+	offset int64
+	length int64
+}
+
+// NewSpillHeap creates a SpillHeap that keeps up to limit values in memory, with a minimum of 2,
+// and writes its runs to dir, or to the default directory for temporary files if dir is empty.
+func NewSpillHeap[t any](limit uint, dir string) *SpillHeap[t] {
+	return &SpillHeap[t]{
+		memory:  NewFibHeap[t](),
+		runs:    NewFibHeap[*spillRun[t]](),
+		spilled: make(map[interface{}]spillEntry[t]),
+		limit:   max(limit, 2),
+		dir:     dir,
+		codec:   GobCodec[t]{},
+	}
+}
+
+// SetCodec replaces the codec used to write spilled data. A nil codec restores the default GobCodec.
+// It must be called before any value is spilled.
+func (spill *SpillHeap[t]) SetCodec(codec Codec[t]) {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if codec == nil {
+		codec = GobCodec[t]{}
+	}
+	spill.codec = codec
+}
+
+// Err returns the first error met while reading a run. The values left in that run are dropped from the heap.
+func (spill *SpillHeap[t]) Err() error {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	return spill.err
+}
+
+// Num returns the total number of values in the heap, in memory and on disk.
+func (spill *SpillHeap[t]) Num() uint {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	return spill.memory.Num() + uint(len(spill.spilled))
+}
+
+// Insert inserts a new value with the given data and priority into the heap,
+// spilling the larger half of the values in memory to disk if the memory limit is reached.
+// Returns an error if the insertion or the spill fails.
+func (spill *SpillHeap[t]) Insert(data t, priority float64) error {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if spill.closed {
+		return ErrClosed
+	}
+
+	if spill.contains(data) {
+		return errors.New("Duplicate data is not allowed ")
+	}
+
+	return spill.insert(data, priority)
+}
+
+// Minimum returns the current minimum data and priority in the heap.
+// Returns -inf if the heap is empty.
+func (spill *SpillHeap[t]) Minimum() (data t, f float64) {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	data, f = spill.memory.Minimum()
+	if run, priority := spill.runs.Minimum(); run != nil && (math.IsInf(f, -1) || priority < f) {
+		return run.head.data, run.head.priority
+	}
+	return data, f
+}
+
+// ExtractMin returns the current minimum data and priority in the heap and then extracts them from the heap.
+// Returns nil/-inf if the heap is empty.
+func (spill *SpillHeap[t]) ExtractMin() (data t, f float64) {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	data, f = spill.memory.Minimum()
+	run, priority := spill.runs.Minimum()
+	if run == nil || (!math.IsInf(f, -1) && f <= priority) {
+		return spill.memory.ExtractMin()
+	}
+
+	data, f = run.head.data, run.head.priority
+	delete(spill.spilled, data)
+	spill.step(run)
+	return data, f
+}
+
+// DecreasePriority decreases the priority of the value with the given data in the heap.
+// A spilled value moves to memory, which may spill other values.
+// Returns an error if the value is not found, the priority is negative infinity, or the spill fails.
+func (spill *SpillHeap[t]) DecreasePriority(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	entry, exists := spill.spilled[data]
+	if !exists {
+		return spill.memory.DecreasePriority(data, priority)
+	}
+	if !(priority < entry.priority) {
+		return errors.New("New priority is not smaller than current priority ")
+	}
+
+	return spill.move(data, priority)
+}
+
+// IncreasePriority increases the priority of the value with the given data in the heap.
+// A spilled value moves to memory, which may spill other values.
+// Returns an error if the value is not found, the priority is negative infinity, or the spill fails.
+func (spill *SpillHeap[t]) IncreasePriority(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	entry, exists := spill.spilled[data]
+	if !exists {
+		return spill.memory.IncreasePriority(data, priority)
+	}
+	if !(priority > entry.priority) {
+		return errors.New("New priority is not larger than current priority ")
+	}
+
+	return spill.move(data, priority)
+}
+
+// Delete removes the value with the given data from the heap.
+// Returns an error if the data is not found.
+func (spill *SpillHeap[t]) Delete(data t) error {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if _, exists := spill.spilled[data]; exists {
+		spill.forget(data)
+		return nil
+	}
+
+	return spill.memory.Delete(data)
+}
+
+// GetPriority returns the priority of the value with the given data in the heap.
+// Returns -inf if the value is not found.
+func (spill *SpillHeap[t]) GetPriority(data t) (priority float64) {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if entry, exists := spill.spilled[data]; exists {
+		return entry.priority
+	}
+
+	return spill.memory.GetPriority(data)
+}
+
+// Union merges the input heap into the target heap, spilling values as needed.
+// Returns an error if any duplicate data are found in the target heap,
+// or the error of a failed spill, in which case the values merged before it stay in the heap.
+func (spill *SpillHeap[t]) Union(anotherHeap *FibHeap[t]) error {
+	items := anotherHeap.items()
+
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if spill.closed {
+		return ErrClosed
+	}
+
+	for _, item := range items {
+		if spill.contains(item.data) {
+			return errors.New("Duplicate data is found in the target heap")
+		}
+	}
+
+	for _, item := range items {
+		if err := spill.insert(item.data, item.priority); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close removes the runs from disk and discards every value. Further inserts return ErrClosed.
+// Returns ErrClosed if the heap was already closed.
+func (spill *SpillHeap[t]) Close() error {
+	spill.mutex.Lock()
+	defer spill.mutex.Unlock()
+
+	if spill.closed {
+		return ErrClosed
+	}
+
+	spill.closed = true
+	for spill.runs.Num() > 0 {
+		run, _ := spill.runs.ExtractMin()
+		run.close()
+	}
+	spill.memory = NewFibHeap[t]()
+	spill.spilled = make(map[interface{}]spillEntry[t])
+	return nil
+}
+
+// contains reports whether the heap holds a value with the given data, in memory or on disk.
+func (spill *SpillHeap[t]) contains(data t) bool {
+	if _, exists := spill.spilled[data]; exists {
+		return true
+	}
+	return !math.IsInf(spill.memory.GetPriority(data), -1)
+}
+
+// insert inserts a value into memory, spilling first if the memory limit is reached.
+func (spill *SpillHeap[t]) insert(data t, priority float64) error {
+	if spill.memory.Num() >= spill.limit {
+		if err := spill.spill(); err != nil {
+			return err
+		}
+	}
+
+	return spill.memory.Insert(data, priority)
+}
+
+// move takes the spilled value with the given data off disk and inserts it into memory with priority.
+// Room is made in memory first, so that a failed spill leaves the value where it was.
+func (spill *SpillHeap[t]) move(data t, priority float64) error {
+	if spill.memory.Num() >= spill.limit {
+		if err := spill.spill(); err != nil {
+			return err
+		}
+	}
+
+	spill.forget(data)
+	return spill.memory.Insert(data, priority)
+}
+
+// forget drops the spilled value with the given data from the index, which turns its record into a tombstone,
+// and moves its run past it if it is the head.
+func (spill *SpillHeap[t]) forget(data t) {
+	run := spill.spilled[data].run
+	delete(spill.spilled, data)
+
+	if any(run.head.data) == any(data) {
+		spill.step(run)
+	}
+}
+
+// live reports whether the value with the given data read from run is still in the heap.
+func (spill *SpillHeap[t]) live(run *spillRun[t], data t) bool {
+	entry, exists := spill.spilled[data]
+	return exists && entry.run == run
+}
+
+// step moves a run of the heap past its head. If the run cannot be read,
+// the values left in it are dropped and the error is kept for Err.
+func (spill *SpillHeap[t]) step(run *spillRun[t]) {
+	err := spill.advance(spill.runs, run)
+	if err == nil {
+		return
+	}
+
+	for data, entry := range spill.spilled {
+		if entry.run == run {
+			delete(spill.spilled, data)
+		}
+	}
+	if spill.err == nil {
+		spill.err = err
+	}
+}
+
+// spill writes the larger half of the values in memory to a new run,
+// and merges every run into one once there are more than maxRuns of them.
+// A failed spill or merge leaves the values where they were.
+func (spill *SpillHeap[t]) spill() error {
+	sorted := make([]item[t], 0, spill.memory.Num())
+	for spill.memory.Num() > 0 {
+		data, priority := spill.memory.ExtractMin()
+		sorted = append(sorted, item[t]{data: data, priority: priority})
+	}
+	keep, spilled := sorted[:len(sorted)/2], sorted[len(sorted)/2:]
+
+	next := 0
+	run, err := spill.writeRun(func() (data t, f float64, err error) {
+		if next == len(spilled) {
+			return data, math.Inf(-1), nil
+		}
+		next++
+		return spilled[next-1].data, spilled[next-1].priority, nil
+	})
+	if err != nil {
+		keep = sorted
+	} else {
+		spill.runs.Insert(run, run.head.priority)
+		for _, item := range spilled {
+			spill.spilled[item.data] = spillEntry[t]{run: run, priority: item.priority}
+		}
+	}
+	for _, item := range keep {
+		spill.memory.Insert(item.data, item.priority)
+	}
+	if err != nil || spill.runs.Num() <= maxRuns {
+		return err
+	}
+
+	return spill.merge()
+}
+
+// merge replaces the runs with a single run holding all of their live values.
+// It reads the runs through cursors of their own, so that the runs are untouched if it fails.
+func (spill *SpillHeap[t]) merge() error {
+	cursors := NewFibHeap[*spillRun[t]]()
+	defer func() {
+		for cursors.Num() > 0 {
+			cursor, _ := cursors.ExtractMin()
+			cursor.close()
+		}
+	}()
+
+	for _, run := range spill.runs.items() {
+		cursor, err := run.data.cursor(spill.codec)
+		if err != nil {
+			return err
+		}
+		cursors.Insert(cursor, cursor.head.priority)
+	}
+
+	merged, err := spill.writeRun(func() (data t, f float64, err error) {
+		cursor, f := cursors.Minimum()
+		if cursor == nil {
+			return data, f, nil
+		}
+		data = cursor.head.data
+		return data, f, spill.advance(cursors, cursor)
+	})
+	if err != nil {
+		return err
+	}
+
+	for spill.runs.Num() > 0 {
+		run, _ := spill.runs.ExtractMin()
+		run.close()
+	}
+	spill.runs.Insert(merged, merged.head.priority)
+	for data, entry := range spill.spilled {
+		spill.spilled[data] = spillEntry[t]{run: merged, priority: entry.priority}
+	}
+	return nil
+}
+
+// writeRun writes the values returned by next, in increasing order until it returns -inf, to a new run.
+// At least one value must be written.
+func (spill *SpillHeap[t]) writeRun(next func() (t, float64, error)) (*spillRun[t], error) {
+	file, err := os.CreateTemp(spill.dir, "fibheap-run-*")
+	if err != nil {
+		return nil, err
+	}
+	run := &spillRun[t]{file: file, owned: true}
+	run.origin = run
+
+	writer := bufio.NewWriter(file)
+	for {
+		data, priority, err := next()
+		if err != nil {
+			run.close()
+			return nil, err
+		}
+		if math.IsInf(priority, -1) {
+			break
+		}
+
+		payload, err := encodeOp(spill.codec, op[t]{kind: opInsert, data: data, priority: priority})
+		if err != nil {
+			run.close()
+			return nil, err
+		}
+		if _, err := writer.Write(appendRecord(nil, payload)); err != nil {
+			run.close()
+			return nil, err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		run.close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		run.close()
+		return nil, err
+	}
+	run.reader = bufio.NewReader(file)
+
+	if err := run.next(spill.codec); err != nil {
+		run.close()
+		return nil, err
+	}
+	return run, nil
+}
+
+// advance moves a run of runs past its head and the tombstones that follow, and re-prioritizes it,
+// or removes and closes it once it is exhausted.
+func (spill *SpillHeap[t]) advance(runs *FibHeap[*spillRun[t]], run *spillRun[t]) error {
+	old := run.head.priority
+	for {
+		if err := run.next(spill.codec); err != nil {
+			runs.Delete(run)
+			run.close()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if spill.live(run.origin, run.head.data) {
+			break
+		}
+	}
+
+	_, err := runs.CompareAndSetPriority(run, old, run.head.priority)
+	return err
+}
+
+// next reads the record that follows the head of the run. Returns io.EOF once the run is exhausted.
+func (run *spillRun[t]) next(codec Codec[t]) error {
+	payload, err := readRecord(run.reader)
+	if err != nil {
+		return err
+	}
+
+	o, err := decodeOp(codec, payload)
+	if err != nil {
+		return err
+	}
+
+	run.offset, run.length = run.offset+run.length, int64(recordHeader+len(payload))
+	run.head = item[t]{data: o.data, priority: o.priority}
+	return nil
+}
+
+// cursor opens a second reader on the file of the run, positioned at its head.
+// Closing the cursor leaves the file in place.
+func (run *spillRun[t]) cursor(codec Codec[t]) (*spillRun[t], error) {
+	file, err := os.Open(run.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(run.offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	cursor := &spillRun[t]{file: file, reader: bufio.NewReader(file), origin: run.origin, offset: run.offset}
+	if err := cursor.next(codec); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// close closes the file of the run, and deletes it if the run owns it.
+func (run *spillRun[t]) close() {
+	run.file.Close()
+	if run.owned {
+		os.Remove(run.file.Name())
+	}
+}
@@ -0,0 +1,170 @@
+package fibheap_test
+
+import (
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tests of spillHeap", func() {
+	var (
+		dir   string
+		spill *fibheap.SpillHeap[int]
+	)
+
+	runs := func() int {
+		entries, err := os.ReadDir(dir)
+		Expect(err).ShouldNot(HaveOccurred())
+		return len(entries)
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		spill = fibheap.NewSpillHeap[int](8, dir)
+	})
+
+	AfterEach(func() {
+		spill.Close()
+		spill = nil
+	})
+
+	It("Given a spillHeap with more values than its limit, when call ExtractMin api, it should return every value in priority order and remove its runs.", func() {
+		for _, i := range rand.Perm(2000) {
+			Expect(spill.Insert(i, float64(i))).ShouldNot(HaveOccurred())
+		}
+		Expect(spill.Num()).Should(BeEquivalentTo(2000))
+		Expect(runs()).Should(BeNumerically(">", 0))
+		Expect(runs()).Should(BeNumerically("<=", 65))
+
+		data, priority := spill.Minimum()
+		Expect(data).Should(BeEquivalentTo(0))
+		Expect(priority).Should(BeEquivalentTo(0))
+
+		for i := 0; i < 2000; i++ {
+			data, priority := spill.ExtractMin()
+			Expect(data).Should(BeEquivalentTo(i))
+			Expect(priority).Should(BeEquivalentTo(i))
+		}
+
+		_, priority = spill.ExtractMin()
+		Expect(priority).Should(BeEquivalentTo(math.Inf(-1)))
+		Expect(spill.Num()).Should(BeEquivalentTo(0))
+		Expect(runs()).Should(BeEquivalentTo(0))
+		Expect(spill.Err()).ShouldNot(HaveOccurred())
+	})
+
+	It("Given a spillHeap, when inserts and extractions are interleaved, it should match a fibHeap.", func() {
+		reference := fibheap.NewFibHeap[int]()
+		present := map[int]bool{}
+		for i := 0; i < 5000; i++ {
+			if rand.Intn(3) == 0 {
+				data, priority := spill.ExtractMin()
+				_, expected := reference.ExtractMin()
+				Expect(priority).Should(Equal(expected))
+				if !math.IsInf(priority, -1) {
+					Expect(present).Should(HaveKey(data))
+					delete(present, data)
+				}
+				continue
+			}
+			priority := float64(rand.Intn(100))
+			Expect(spill.Insert(i, priority)).ShouldNot(HaveOccurred())
+			reference.Insert(i, priority)
+			present[i] = true
+		}
+		Expect(spill.Num()).Should(Equal(reference.Num()))
+	})
+
+	It("Given a spillHeap with spilled values, when data is inserted again, it should reject the duplicate.", func() {
+		for i := 0; i < 100; i++ {
+			spill.Insert(i, float64(i))
+		}
+		Expect(runs()).Should(BeNumerically(">", 0))
+
+		Expect(spill.Insert(2, 5)).Should(HaveOccurred())
+		Expect(spill.Insert(99, 5)).Should(HaveOccurred())
+		Expect(spill.Num()).Should(BeEquivalentTo(100))
+		Expect(spill.GetPriority(99)).Should(BeEquivalentTo(99))
+	})
+
+	It("Given a spillHeap with spilled values, when they are deleted and reprioritized, it should match a fibHeap.", func() {
+		reference := fibheap.NewFibHeap[int]()
+		for _, i := range rand.Perm(500) {
+			priority := float64(rand.Intn(1000))
+			spill.Insert(i, priority)
+			reference.Insert(i, priority)
+		}
+
+		for i := 0; i < 2000; i++ {
+			data := rand.Intn(600)
+			priority := float64(rand.Intn(1000))
+			switch rand.Intn(5) {
+			case 0:
+				Expect(spill.Delete(data) == nil).Should(Equal(reference.Delete(data) == nil))
+			case 1:
+				Expect(spill.DecreasePriority(data, priority) == nil).Should(Equal(reference.DecreasePriority(data, priority) == nil))
+			case 2:
+				Expect(spill.IncreasePriority(data, priority) == nil).Should(Equal(reference.IncreasePriority(data, priority) == nil))
+			case 3:
+				Expect(spill.Insert(data, priority) == nil).Should(Equal(reference.Insert(data, priority) == nil))
+			case 4:
+				// Ties may be broken differently, so the value extracted from the spillHeap is deleted from the reference.
+				extracted, priority := spill.ExtractMin()
+				_, expected := reference.Minimum()
+				Expect(priority).Should(Equal(expected))
+				if !math.IsInf(priority, -1) {
+					Expect(reference.GetPriority(extracted)).Should(Equal(priority))
+					reference.Delete(extracted)
+				}
+			}
+			Expect(spill.GetPriority(data)).Should(Equal(reference.GetPriority(data)))
+		}
+
+		Expect(spill.Num()).Should(Equal(reference.Num()))
+		for reference.Num() > 0 {
+			data, priority := spill.ExtractMin()
+			Expect(priority).Should(Equal(reference.GetPriority(data)))
+			Expect(reference.Delete(data)).ShouldNot(HaveOccurred())
+		}
+		Expect(spill.Num()).Should(BeEquivalentTo(0))
+		Expect(runs()).Should(BeEquivalentTo(0))
+		Expect(spill.Err()).ShouldNot(HaveOccurred())
+	})
+
+	It("Given a spillHeap, when call Union api, it should merge the values of the fibHeap and reject duplicates.", func() {
+		for i := 0; i < 20; i++ {
+			spill.Insert(i, float64(i))
+		}
+		another := fibheap.NewFibHeap[int]()
+		for i := 20; i < 40; i++ {
+			another.Insert(i, float64(-i))
+		}
+
+		Expect(spill.Union(another)).ShouldNot(HaveOccurred())
+		Expect(spill.Num()).Should(BeEquivalentTo(40))
+		Expect(spill.Union(another)).Should(HaveOccurred())
+		Expect(spill.Num()).Should(BeEquivalentTo(40))
+
+		data, priority := spill.ExtractMin()
+		Expect(data).Should(BeEquivalentTo(39))
+		Expect(priority).Should(BeEquivalentTo(-39))
+	})
+
+	It("Given a spillHeap with runs, when call Close api, it should remove its runs and reject inserts.", func() {
+		for i := 0; i < 100; i++ {
+			spill.Insert(i, float64(i))
+		}
+		Expect(spill.Insert(0, 0)).Should(HaveOccurred())
+
+		Expect(spill.Close()).ShouldNot(HaveOccurred())
+		Expect(runs()).Should(BeEquivalentTo(0))
+		Expect(spill.Num()).Should(BeEquivalentTo(0))
+		Expect(spill.Insert(100, 100)).Should(MatchError(fibheap.ErrClosed))
+		Expect(spill.Close()).Should(MatchError(fibheap.ErrClosed))
+	})
+})
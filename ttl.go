@@ -0,0 +1,80 @@
+package fibheap
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// InsertWithTTL inserts a new value with the given data and priority into the heap, which expires after ttl.
+// Expired values are removed lazily by Minimum, ExtractMin, ExtractMinWait and Lease, or by Sweep,
+// and each removal fires the OnExpire hook.
+// Returns an error if the insertion fails.
+func (heap *FibHeap[t]) InsertWithTTL(data t, priority float64, ttl time.Duration) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	if err := heap.insertWait(context.Background(), data, heap.toKey(priority)); err != nil {
+		return err
+	}
+
+	if heap.expiry == nil {
+		heap.expiry = NewFibHeap[t]()
+	}
+	heap.expiry.insert(data, TimePriority(heap.clock.Now().Add(ttl)))
+
+	return nil
+}
+
+// Sweep removes every expired value from the heap and returns how many were removed.
+// It only visits the expired values, in O(expired · log n).
+func (heap *FibHeap[t]) Sweep() int {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	return heap.expire()
+}
+
+// expire removes the values whose deadline has passed, earliest first.
+func (heap *FibHeap[t]) expire() int {
+	if heap.expiry == nil {
+		return 0
+	}
+
+	now := TimePriority(heap.clock.Now())
+	expired := 0
+	for heap.expiry.num > 0 && heap.expiry.min.priority <= now {
+		heap.removeNode(heap.index[heap.expiry.min.data], eventExpire)
+		expired++
+	}
+
+	return expired
+}
+
+// deadline returns the expiry priority of the value with the given data, or -inf if it never expires.
+func (heap *FibHeap[t]) deadline(data t) float64 {
+	if heap.expiry == nil {
+		return math.Inf(-1)
+	}
+
+	if node, exists := heap.expiry.index[data]; exists {
+		return node.priority
+	}
+
+	return math.Inf(-1)
+}
+
+// reinsert puts a removed value back along with its expiry and context binding, to undo the removal
+// or to end a lease. Returns an error if the value cannot be inserted.
+func (heap *FibHeap[t]) reinsert(data t, priority, deadline float64, b *binding) error {
+	if err := heap.insert(data, priority); err != nil {
+		return err
+	}
+	if !math.IsInf(deadline, -1) {
+		heap.expiry.insert(data, deadline)
+	}
+	if b != nil {
+		heap.bind(b.ctx, data)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package fibheap
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Tx gives access to a heap inside Update. Its methods behave like the FibHeap methods
+// of the same name, but they all run under the single lock held by Update.
+// A Tx must not be used after Update returns.
+type Tx[t any] struct {
+	heap *FibHeap[t]
+}
+
+// Update runs fn with the heap locked, so other goroutines observe all of its operations at once.
+// If fn returns an error or panics, every change it made is rolled back, leaving the heap
+// with the same values and priorities as before. fn must not call methods of the heap itself.
+func (heap *FibHeap[t]) Update(fn func(tx *Tx[t]) error) error {
+	heap.mutex.Lock()
+	defer heap.unlock()
+
+	committed := false
+	heap.journal = make([]func(), 0)
+	defer func() {
+		journal := heap.journal
+		heap.journal = nil
+
+		if !committed {
+			for i := len(journal) - 1; i >= 0; i-- {
+				journal[i]()
+			}
+			heap.events = nil
+			return
+		}
+
+		heap.cond.Broadcast()
+		heap.space.Broadcast()
+	}()
+
+	if err := fn(&Tx[t]{heap: heap}); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// Num returns the total number of values in the heap.
+func (tx *Tx[t]) Num() uint {
+	return tx.heap.num
+}
+
+// Insert inserts a new value with the given data and priority into the heap.
+// A full heap with the Block policy returns ErrFull instead of waiting.
+// Returns an error if the insertion fails.
+func (tx *Tx[t]) Insert(data t, priority float64) error {
+	heap := tx.heap
+
+	if heap.closed {
+		return ErrClosed
+	}
+
+	if err := heap.validate(data, priority); err != nil {
+		return err
+	}
+
+	size := heap.sizeOf(data)
+	if heap.policy == Block && heap.full(1, size) {
+		return ErrFull
+	}
+
+	key := heap.toKey(priority)
+	if err := heap.makeRoom(context.Background(), key, size); err != nil {
+		return err
+	}
+
+	return heap.insert(data, key)
+}
+
+// Minimum returns the current minimum data and priority in the heap.
+// Returns -inf if the heap is empty.
+func (tx *Tx[t]) Minimum() (data t, f float64) {
+	if tx.heap.num == 0 {
+		return data, math.Inf(-1)
+	}
+
+	return tx.heap.min.data, tx.heap.fromKey(tx.heap.min.priority)
+}
+
+// ExtractMin returns the current minimum data and priority in the heap and then extracts them from the heap.
+// Returns nil/-inf if the heap is empty.
+func (tx *Tx[t]) ExtractMin() (data t, f float64) {
+	if tx.heap.num == 0 {
+		return data, math.Inf(-1)
+	}
+
+	min := tx.heap.extractMin()
+	return min.data, tx.heap.fromKey(min.priority)
+}
+
+// DecreasePriority decreases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found or the priority is negative infinity.
+func (tx *Tx[t]) DecreasePriority(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+
+	if node, exists := tx.heap.index[data]; exists {
+		return tx.heap.decreaseKey(node, tx.heap.toKey(priority))
+	}
+
+	return errors.New("Value is not found")
+}
+
+// IncreasePriority increases the priority of the value with the given data in the heap.
+// Returns an error if the value is not found or the priority is negative infinity.
+func (tx *Tx[t]) IncreasePriority(data t, priority float64) error {
+	if math.IsInf(priority, -1) {
+		return errors.New("Negative infinity priority is reserved for internal usage")
+	}
+
+	if node, exists := tx.heap.index[data]; exists {
+		return tx.heap.increaseKey(node, tx.heap.toKey(priority))
+	}
+
+	return errors.New("Value is not found")
+}
+
+// Delete removes the value with the given data from the heap.
+// Returns an error if the data is not found.
+func (tx *Tx[t]) Delete(data t) error {
+	node, exists := tx.heap.index[data]
+	if !exists {
+		return errors.New("Tag is not found")
+	}
+
+	tx.heap.deleteNode(node)
+
+	return nil
+}
+
+// GetPriority returns the priority of the value with the given data in the heap.
+// Returns -inf if the value is not found.
+func (tx *Tx[t]) GetPriority(data t) (priority float64) {
+	if node, exists := tx.heap.index[data]; exists {
+		return tx.heap.fromKey(node.priority)
+	}
+
+	return math.Inf(-1)
+}
@@ -1,26 +1,78 @@
 package fibheap
 
 import (
-	"container/list"
 	"sync"
+	"time"
 )
 
 type FibHeap[t any] struct {
-	roots       *list.List
-	index       map[interface{}]*node[t]
-	treeDegrees map[uint]*list.Element
-	min         *node[t]
-	num         uint
-	mutex       sync.Mutex
+	roots           *node[t]
+	index           map[interface{}]*node[t]
+	treeDegrees     []*node[t]
+	min             *node[t]
+	num             uint
+	mutex           sync.Mutex
+	cond            *sync.Cond
+	space           *sync.Cond
+	closed          bool
+	capacity        uint
+	budget          int
+	weight          int
+	leaseWeight     int
+	sizer           func(t) int
+	policy          OverflowPolicy
+	maxHeap         *maxHeap[t]
+	journal         []func()
+	hooks           *Hooks[t]
+	events          []event[t]
+	lastMinData     t
+	lastMinPriority float64
+	clock           Clock
+	leases          map[interface{}]*lease[t]
+	retry           RetryPolicy
+	attempts        map[interface{}]*retryState
+	deadLetters     *FibHeap[t]
+	expiry          *FibHeap[t]
+	agingRate       float64
+	agingInterval   time.Duration
+	agingSince      time.Time
+	offset          float64
+	bound           map[interface{}]*binding
+	codec           Codec[t]
+	slab            []node[t]
+	pool            *node[t]
+	poolNum         int
+	poolMax         int
 }
 
+// node is linked to its siblings through left and right, in a circular doubly-linked list,
+// and to the first of its children through child.
 type node[t any] struct {
-	self     *list.Element
 	parent   *node[t]
-	children *list.List
+	child    *node[t]
+	left     *node[t]
+	right    *node[t]
 	marked   bool
 	degree   uint
-	position uint
+	maxIndex int
+	size     int
 	data     t
 	priority float64
 }
+
+type item[t any] struct {
+	data     t
+	priority float64
+}
+
+// OverflowPolicy decides what Insert does when a bounded heap is full.
+type OverflowPolicy int
+
+const (
+	// Reject makes Insert fail with ErrFull.
+	Reject OverflowPolicy = iota
+	// Block makes Insert wait until space is freed.
+	Block
+	// Evict makes Insert remove the value with the largest priority.
+	Evict
+)
@@ -0,0 +1,260 @@
+package fibheap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DurableHeap is a FibHeap whose mutations are appended to a write-ahead log, so that it survives crashes.
+// A mutation is applied and logged as one transaction: it only becomes visible once its record is in the log,
+// and it is rolled back if the record cannot be written.
+// The log lives at the path given to OpenDurableHeap, and compactions write a snapshot next to it.
+type DurableHeap[t any] struct {
+	opLog[t]
+	codec        Codec[t]
+	path         string
+	file         *os.File
+	size         int64
+	records      int
+	compactEvery int
+	sync         bool
+}
+
+// OpenDurableHeap opens the durable heap logged at path, creating it if needed, and restores its values
+// from the last snapshot and the log. A record torn by a crash at the end of the log is discarded.
+// Data is encoded with codec, or GobCodec if codec is nil.
+// Returns ErrCorrupt if the snapshot or the log is damaged anywhere but at the end.
+func OpenDurableHeap[t any](path string, codec Codec[t]) (*DurableHeap[t], error) {
+	if codec == nil {
+		codec = GobCodec[t]{}
+	}
+
+	durable := &DurableHeap[t]{codec: codec, path: path, sync: true}
+	durable.heap = NewFibHeap[t]()
+	durable.heap.SetCodec(codec)
+	durable.write = durable.append
+	durable.committed = durable.autoCompact
+
+	if err := durable.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := durable.replay(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	durable.file = file
+
+	return durable, nil
+}
+
+// SetSync sets whether every record is flushed to stable storage before its mutation returns. Defaults to true.
+func (durable *DurableHeap[t]) SetSync(sync bool) {
+	durable.mutex.Lock()
+	defer durable.mutex.Unlock()
+
+	durable.sync = sync
+}
+
+// SetCompaction makes the heap compact itself after every records appended records. Zero disables it.
+// A failed compaction is returned by the mutation that triggered it, which stays committed.
+func (durable *DurableHeap[t]) SetCompaction(records int) {
+	durable.mutex.Lock()
+	defer durable.mutex.Unlock()
+
+	durable.compactEvery = records
+}
+
+// Compact writes a snapshot of the heap and empties the log.
+// A crash at any point leaves either the old or the new snapshot, together with a log that replays on top of it.
+func (durable *DurableHeap[t]) Compact() error {
+	durable.mutex.Lock()
+	defer durable.mutex.Unlock()
+
+	if durable.closed {
+		return ErrClosed
+	}
+
+	return durable.compact()
+}
+
+// Close closes the log. The values are kept in the log and the snapshot for the next OpenDurableHeap.
+// Returns ErrClosed if the heap was already closed.
+func (durable *DurableHeap[t]) Close() error {
+	durable.mutex.Lock()
+	defer durable.mutex.Unlock()
+
+	if durable.closed {
+		return ErrClosed
+	}
+
+	durable.closed = true
+	durable.heap.Close()
+	return durable.file.Close()
+}
+
+// autoCompact compacts the heap once the log holds the number of records set by SetCompaction.
+func (durable *DurableHeap[t]) autoCompact() error {
+	if durable.compactEvery > 0 && durable.records >= durable.compactEvery {
+		return durable.compact()
+	}
+	return nil
+}
+
+// append writes o to the log.
+// A failed write is cut off the log so that it cannot tear the records that follow.
+func (durable *DurableHeap[t]) append(o op[t]) error {
+	payload, err := encodeOp(durable.codec, o)
+	if err != nil {
+		return err
+	}
+
+	record := appendRecord(nil, payload)
+	if _, err = durable.file.Write(record); err == nil && durable.sync {
+		err = durable.file.Sync()
+	}
+	if err != nil {
+		durable.file.Truncate(durable.size)
+		return err
+	}
+
+	durable.size += int64(len(record))
+	durable.records++
+	return nil
+}
+
+// replay applies the records of the log that follow the snapshot, and cuts off a torn record at its end.
+// A record that runs past the end of the log but is followed by a valid one has a damaged length instead.
+func (durable *DurableHeap[t]) replay(file *os.File) error {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	reader := bytes.NewReader(content)
+	valid := int64(0)
+	for {
+		payload, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		torn := err == errTorn && !recordFollows(content[min(valid+recordHeader, int64(len(content))):])
+		if torn || (err == ErrCorrupt && reader.Len() == 0) {
+			if err := file.Truncate(valid); err != nil {
+				return err
+			}
+			break
+		}
+		if err == errTorn {
+			return ErrCorrupt
+		}
+		if err != nil {
+			return err
+		}
+
+		o, err := decodeOp(durable.codec, payload)
+		if err != nil {
+			return ErrCorrupt
+		}
+		if o.seq > durable.seq {
+			if o.seq != durable.seq+1 || durable.heap.Update(o.apply) != nil {
+				return ErrCorrupt
+			}
+			durable.seq = o.seq
+			durable.records++
+		}
+		valid = int64(len(content) - reader.Len())
+	}
+
+	durable.size = valid
+	return nil
+}
+
+// snapshotPath returns the path of the snapshot written by compactions.
+func (durable *DurableHeap[t]) snapshotPath() string {
+	return durable.path + ".snapshot"
+}
+
+// loadSnapshot restores the heap from the snapshot, if there is one.
+// The snapshot is a single record holding the sequence number of the last op it includes and the heap.
+func (durable *DurableHeap[t]) loadSnapshot() error {
+	content, err := os.ReadFile(durable.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := readRecord(bytes.NewReader(content))
+	if err != nil {
+		return ErrCorrupt
+	}
+	seq, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return ErrCorrupt
+	}
+	if err := durable.heap.UnmarshalBinary(payload[n:]); err != nil {
+		return err
+	}
+
+	durable.seq = seq
+	return nil
+}
+
+// compact writes the snapshot to a temporary file, renames it into place and then empties the log.
+func (durable *DurableHeap[t]) compact() error {
+	heap, err := durable.heap.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	payload := append(binary.AppendUvarint(nil, durable.seq), heap...)
+
+	temp := durable.snapshotPath() + ".tmp"
+	if err := writeFileSync(temp, appendRecord(nil, payload)); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, durable.snapshotPath()); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(durable.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	if err := durable.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := durable.file.Sync(); err != nil {
+		return err
+	}
+
+	durable.size = 0
+	durable.records = 0
+	return nil
+}
+
+// writeFileSync writes content to a new file at path and flushes it to stable storage.
+func writeFileSync(path string, content []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,160 @@
+package fibheap_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/JustinTimperio/fibheap"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tests of durableHeap", func() {
+	var (
+		path    string
+		durable *fibheap.DurableHeap[int]
+	)
+
+	reopen := func() {
+		if durable != nil {
+			durable.Close()
+		}
+		var err error
+		durable, err = fibheap.OpenDurableHeap[int](path, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	drain := func() []int {
+		var values []int
+		for durable.Num() > 0 {
+			data, _, err := durable.ExtractMin()
+			Expect(err).ShouldNot(HaveOccurred())
+			values = append(values, data)
+		}
+		return values
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "heap.log")
+		durable = nil
+		reopen()
+	})
+
+	AfterEach(func() {
+		durable.Close()
+		durable = nil
+	})
+
+	It("Given a durableHeap with logged mutations, when it is reopened, it should restore the same values and priorities.", func() {
+		for i := 1; i <= 5; i++ {
+			Expect(durable.Insert(i, float64(i))).ShouldNot(HaveOccurred())
+		}
+		Expect(durable.Insert(1, 1)).Should(HaveOccurred())
+		data, priority, err := durable.ExtractMin()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(data).Should(BeEquivalentTo(1))
+		Expect(priority).Should(BeEquivalentTo(1))
+		Expect(durable.DecreasePriority(5, 0)).ShouldNot(HaveOccurred())
+		Expect(durable.IncreasePriority(2, 10)).ShouldNot(HaveOccurred())
+		Expect(durable.Delete(3)).ShouldNot(HaveOccurred())
+
+		another := fibheap.NewFibHeap[int]()
+		another.Insert(6, 6)
+		another.Insert(7, -1)
+		Expect(durable.Union(another)).ShouldNot(HaveOccurred())
+		Expect(durable.Union(another)).Should(HaveOccurred())
+
+		reopen()
+		Expect(durable.GetPriority(2)).Should(BeEquivalentTo(10))
+		Expect(drain()).Should(Equal([]int{7, 5, 4, 6, 2}))
+
+		reopen()
+		Expect(durable.Num()).Should(BeEquivalentTo(0))
+	})
+
+	It("Given a durableHeap whose last record was torn by a crash, when it is reopened, it should discard only that record.", func() {
+		durable.Insert(1, 1)
+		durable.Insert(2, 2)
+		durable.Close()
+		durable = nil
+
+		info, _ := os.Stat(path)
+		Expect(os.Truncate(path, info.Size()-3)).ShouldNot(HaveOccurred())
+
+		reopen()
+		Expect(durable.Num()).Should(BeEquivalentTo(1))
+		Expect(durable.Insert(3, 3)).ShouldNot(HaveOccurred())
+
+		reopen()
+		Expect(drain()).Should(Equal([]int{1, 3}))
+	})
+
+	It("Given a durableHeap whose log is damaged before its end, when it is reopened, it should return ErrCorrupt.", func() {
+		durable.Insert(1, 1)
+		durable.Insert(2, 2)
+		durable.Close()
+		durable = nil
+
+		content, _ := os.ReadFile(path)
+		content[len(content)/2-1] ^= 0xff
+		os.WriteFile(path, content, 0o644)
+
+		_, err := fibheap.OpenDurableHeap[int](path, nil)
+		Expect(err).Should(MatchError(fibheap.ErrCorrupt))
+		os.Remove(path)
+		reopen()
+	})
+
+	It("Given a durableHeap whose first record has a damaged length, when it is reopened, it should return ErrCorrupt and keep the log.", func() {
+		for i := 1; i <= 10; i++ {
+			durable.Insert(i, float64(i))
+		}
+		durable.Close()
+		durable = nil
+
+		content, _ := os.ReadFile(path)
+		damaged := append([]byte(nil), content...)
+		damaged[1] = 0xff
+		os.WriteFile(path, damaged, 0o644)
+
+		_, err := fibheap.OpenDurableHeap[int](path, nil)
+		Expect(err).Should(MatchError(fibheap.ErrCorrupt))
+		info, _ := os.Stat(path)
+		Expect(info.Size()).Should(BeEquivalentTo(len(content)))
+
+		os.WriteFile(path, content, 0o644)
+		reopen()
+		Expect(durable.Num()).Should(BeEquivalentTo(10))
+	})
+
+	It("Given a durableHeap with compaction, when it is reopened, it should restore the values from the snapshot and the log.", func() {
+		durable.SetCompaction(3)
+		for i := 1; i <= 5; i++ {
+			durable.Insert(i, float64(-i))
+		}
+		info, _ := os.Stat(path)
+		Expect(info.Size()).Should(BeNumerically(">", 0))
+		_, err := os.Stat(path + ".snapshot")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		reopen()
+		Expect(drain()).Should(Equal([]int{5, 4, 3, 2, 1}))
+	})
+
+	It("Given a durableHeap that crashed after writing a snapshot but before emptying the log, when it is reopened, it should not apply the log twice.", func() {
+		durable.Insert(1, 1)
+		durable.Insert(2, 2)
+		log, _ := os.ReadFile(path)
+		Expect(durable.Compact()).ShouldNot(HaveOccurred())
+		durable.Insert(3, 3)
+		durable.Close()
+		durable = nil
+
+		content, _ := os.ReadFile(path)
+		os.WriteFile(path, append(log, content...), 0o644)
+
+		reopen()
+		Expect(drain()).Should(Equal([]int{1, 2, 3}))
+	})
+})